@@ -0,0 +1,79 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config holds the settings which customize the OpenTelemetry plugin's
+// behavior. The zero value is a valid Config.
+//
+// A Config is consumed when the plugin is installed, via either the
+// OnClient or the OnHandlers function, and changing it afterward has no
+// effect on the installed plugin.
+type Config struct {
+	// TracerProvider provides the tracer used to create spans. If nil,
+	// the global TracerProvider is used.
+	TracerProvider trace.TracerProvider
+
+	// Propagator injects the trace context of each attempt span into
+	// the outgoing request headers. If nil, the global
+	// TextMapPropagator is used if one has been set, and otherwise
+	// the W3C Trace Context propagator is used.
+	Propagator propagation.TextMapPropagator
+
+	// Options customize the behavior the plugin shares with package
+	// httpxxray, such as span naming, URL sanitization, header capture,
+	// and classification. Any httpxxray.WithTracer option is
+	// overridden, since the plugin supplies its own tracer.
+	Options []httpxxray.Option
+}
+
+// An Option customizes the Config used to install the OpenTelemetry
+// plugin. Pass options to OnClient or OnHandlers.
+type Option func(*Config)
+
+// WithTracerProvider returns an Option which sets the TracerProvider
+// used to create spans.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Config) {
+		c.TracerProvider = tp
+	}
+}
+
+// WithPropagator returns an Option which sets the TextMapPropagator
+// used to inject trace context into outgoing request headers.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *Config) {
+		c.Propagator = p
+	}
+}
+
+// WithOptions returns an Option which adds httpxxray options to the
+// Config. Use it to configure the behavior the plugin shares with
+// package httpxxray, for example:
+//
+//	httpxotel.WithOptions(
+//		httpxxray.WithURLSanitizer(httpxxray.KeepURL),
+//		httpxxray.WithClassifier(httpxxray.TreatAsSuccess(nil, 404)),
+//	)
+func WithOptions(opts ...httpxxray.Option) Option {
+	return func(c *Config) {
+		c.Options = append(c.Options[:len(c.Options):len(c.Options)], opts...)
+	}
+}
+
+func newConfig(opts []Option) Config {
+	var c Config
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	return c
+}
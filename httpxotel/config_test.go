@@ -0,0 +1,49 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"testing"
+
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("No options", func(t *testing.T) {
+		c := newConfig(nil)
+
+		assert.Equal(t, Config{}, c)
+	})
+	t.Run("nil Option", func(t *testing.T) {
+		c := newConfig([]Option{nil})
+
+		assert.Equal(t, Config{}, c)
+	})
+	t.Run("WithTracerProvider", func(t *testing.T) {
+		tp := sdktrace.NewTracerProvider()
+
+		c := newConfig([]Option{WithTracerProvider(tp)})
+
+		assert.Same(t, tp, c.TracerProvider)
+	})
+	t.Run("WithPropagator", func(t *testing.T) {
+		p := propagation.TraceContext{}
+
+		c := newConfig([]Option{WithPropagator(p)})
+
+		assert.Equal(t, p, c.Propagator)
+	})
+	t.Run("WithOptions", func(t *testing.T) {
+		c := newConfig([]Option{
+			WithOptions(httpxxray.WithBackoff()),
+			WithOptions(httpxxray.WithFacts(httpxxray.FactsAsAnnotations)),
+		})
+
+		assert.Len(t, c.Options, 2)
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package httpxotel adds OpenTelemetry tracing support to the httpx
+library's robust HTTP client. See https://github.com/gogama/httpx.
+
+Package httpxotel is the OpenTelemetry counterpart of package httpxxray.
+It is implemented as an OpenTelemetry tracing backend for httpxxray (see
+httpxxray.Tracer), so it shares httpxxray's handling of the request plan
+execution lifecycle, including retries, racing, timeouts, and
+classification, and produces the same trace structure: one span for the
+whole request plan execution, with one child span for each request
+attempt. Services which export OpenTelemetry traces to AWS X-Ray (for
+example using the AWS Distro for OpenTelemetry) can therefore switch
+from httpxxray to httpxotel without losing the retry and racing
+structure of their traces.
+
+Use the OnClient function to install OpenTelemetry support in any
+httpx.Client:
+
+	cl := &httpx.Client{}               // Create robust HTTP client
+	httpxotel.OnClient(cl)              // Install OpenTelemetry plugin
+
+When creating a request plan for the client to execute, use a context
+containing the parent span, if any.
+
+	pl := request.NewPlanWithContext(   // Make plan using span context
+		ctx,
+		"GET",
+		"https://www.example.com/things/123",
+		nil,
+	)
+	e, err := cl.Do(pl)                 // Send request and read response
+
+Use the OnHandlers function to install OpenTelemetry support directly
+onto an httpx.HandlerGroup.
+
+The plugin may be installed alongside the X-Ray plugin of package
+httpxxray on the same client, in which case both plugins trace each
+request plan execution independently, so that a service can migrate
+from X-Ray to OpenTelemetry gradually. Remove only removes the
+OpenTelemetry plugin, and httpxxray.Remove only removes the X-Ray
+plugin.
+
+By default, the plugin uses the global TracerProvider. Pass Option
+values to OnClient or OnHandlers to change this, or to configure the
+behavior shared with httpxxray using httpxxray options:
+
+	httpxotel.OnClient(cl,
+		httpxotel.WithTracerProvider(tp),
+		httpxotel.WithOptions(httpxxray.WithBackoff()),
+	)
+
+# Trace propagation
+
+Where httpxxray sends the X-Amzn-Trace-Id header by default, this
+plugin injects trace context into each request attempt using an
+OpenTelemetry TextMapPropagator. If no propagator is configured with
+WithPropagator, the global TextMapPropagator is used, unless the
+application has not set one, in which case the W3C Trace Context
+propagator is used and the traceparent header is sent. A service
+migrating from httpxxray whose downstream services still expect
+X-Amzn-Trace-Id must therefore configure an X-Ray propagator, such as
+the one in the go.opentelemetry.io/contrib/propagators/aws/xray
+package, either globally or with WithPropagator.
+
+Alternatively, an httpxxray.Propagator passed using WithOptions and
+httpxxray.WithPropagator replaces the TextMapPropagator entirely. The
+httpxxray propagators translate the OpenTelemetry trace context, so for
+example httpxxray.NewHostPropagator can send X-Amzn-Trace-Id to some
+hosts and traceparent to others.
+
+# Span contents
+
+Each attempt span is a client span carrying the HTTP semantic
+convention attributes http.request.method, url.full, server.address,
+server.port, http.response.status_code and, when known,
+http.response.body.size, and one span event for each connection-level
+operation reported by the net/http/httptrace package, such as DNS
+lookup, connect, and TLS handshake.
+
+Spans of requests to AWS service endpoints also carry the attributes
+rpc.system (with the value aws-api), rpc.service, rpc.method,
+cloud.region and aws.request_id, where known.
+
+The facts which httpxxray records as metadata are recorded as
+attributes named after the metadata namespace and key, for example
+httpx.attempt, httpx.wave and httpx.outcome on attempt spans, and
+httpx.attempts and httpx.waves on the execution span. Facts recorded as
+annotations keep their annotation keys, for example httpx_attempts.
+Likewise, a failed attempt or execution span has the attribute
+error_kind, whose values are those of httpxxray.ErrorKind.
+
+A span classified as a fault or error by the configured
+httpxxray.Classifier has the Error status, and a span classified as a
+throttle has the attribute httpx.throttle.
+*/
+package httpxotel
@@ -0,0 +1,41 @@
+module github.com/gogama/aws-xray-httpx/httpxotel
+
+go 1.20
+
+require (
+	github.com/aws/aws-xray-sdk-go/v2 v2.0.0
+	github.com/gogama/aws-xray-httpx/httpxxray/v2 v2.1.0
+	github.com/gogama/httpx v1.1.1
+	github.com/stretchr/testify v1.8.4
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/klauspost/compress v1.17.6 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasthttp v1.52.0 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/grpc v1.64.1 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The plugin is built on the httpxxray API added in v2.1.0 (Install,
+// WithTracer, Tracer and Span). Release httpxxray/v2.1.0 first, then
+// remove this replace directive, run go mod tidy, and tag httpxotel, so
+// that the module builds for those who depend on it. Until then, the
+// replace directive builds it against the httpxxray module in this
+// repository.
+replace github.com/gogama/aws-xray-httpx/httpxxray/v2 => ../httpxxray
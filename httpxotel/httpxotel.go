@@ -0,0 +1,98 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/gogama/httpx"
+)
+
+const (
+	nilClientMsg       = "httpxotel: nil client"
+	nilHandlerGroupMsg = "httpxotel: nil handler group"
+)
+
+// OnClient installs OpenTelemetry support onto an httpx Client.
+//
+// If client's current handler group is nil, OnClient creates a new
+// handler group, sets it as client's current handler group, and
+// proceeds to install OpenTelemetry support into the handler group. If
+// the handler group is not nil, OnClient adds OpenTelemetry support
+// into the existing handler group. If the plugin is already installed on
+// the handler group, it is reconfigured instead (see httpxxray.Plugin),
+// so the handler group may safely be shared among multiple clients. An
+// X-Ray plugin installed on the handler group by package httpxxray is
+// left in place, so that both plugins trace each request.
+func OnClient(client *httpx.Client, opts ...Option) *httpx.Client {
+	InstallOnClient(client, opts...)
+
+	return client
+}
+
+// InstallOnClient installs OpenTelemetry support onto an httpx Client
+// and returns the installed plugin, whose Stats method reports its
+// health counters.
+//
+// InstallOnClient behaves identically to OnClient, except for its
+// return value.
+func InstallOnClient(client *httpx.Client, opts ...Option) *httpxxray.Plugin {
+	if client == nil {
+		panic(nilClientMsg)
+	}
+
+	handlers := client.Handlers
+	if handlers == nil {
+		handlers = &httpx.HandlerGroup{}
+		client.Handlers = handlers
+	}
+
+	return Install(handlers, opts...)
+}
+
+// OnHandlers installs OpenTelemetry support onto an httpx HandlerGroup.
+//
+// The handler group may not be nil - if it is, a panic will ensue.
+func OnHandlers(handlers *httpx.HandlerGroup, opts ...Option) *httpx.HandlerGroup {
+	Install(handlers, opts...)
+
+	return handlers
+}
+
+// Install installs OpenTelemetry support onto an httpx HandlerGroup and
+// returns the installed plugin, whose Stats method reports its health
+// counters.
+//
+// Install behaves identically to OnHandlers, except for its return
+// value.
+//
+// When built with a Go version before 1.24, the plugin keeps every
+// handler group it is installed on reachable for the life of the
+// program, as described for httpxxray.Install, so install it once on a
+// handler group shared by all clients rather than on a new handler
+// group for each client.
+func Install(handlers *httpx.HandlerGroup, opts ...Option) *httpxxray.Plugin {
+	if handlers == nil {
+		panic(nilHandlerGroupMsg)
+	}
+
+	c := newConfig(opts)
+	t := newTracer(c)
+	xrayOpts := make([]httpxxray.Option, 0, len(c.Options)+2)
+	xrayOpts = append(xrayOpts, httpxxray.WithPropagator(textMapInjector{t}))
+	xrayOpts = append(xrayOpts, c.Options...)
+	xrayOpts = append(xrayOpts, httpxxray.WithTracer(t))
+	return httpxxray.Install(handlers, xrayOpts...)
+}
+
+// Remove removes OpenTelemetry support from an httpx HandlerGroup, and
+// reports whether the plugin was installed on it. An X-Ray plugin
+// installed on the handler group by package httpxxray is not removed.
+func Remove(handlers *httpx.HandlerGroup) bool {
+	if handlers == nil {
+		panic(nilHandlerGroupMsg)
+	}
+
+	return httpxxray.RemoveTracer(handlers, (*tracer)(nil))
+}
@@ -0,0 +1,207 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+	"github.com/gogama/httpx/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOnClient(t *testing.T) {
+	t.Run("nil Client", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilClientMsg, func() {
+			OnClient(nil)
+		})
+	})
+	t.Run("client has nil Handlers", func(t *testing.T) {
+		cl := &httpx.Client{}
+		OnClient(cl)
+		assert.NotNil(t, cl.Handlers)
+	})
+	t.Run("everything", func(t *testing.T) {
+		cl := &httpx.Client{
+			Handlers: &httpx.HandlerGroup{},
+		}
+		OnClient(cl, WithTracerProvider(sdktrace.NewTracerProvider()))
+	})
+}
+
+func TestOnHandlers(t *testing.T) {
+	t.Run("nil HandlerGroup", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			OnHandlers(nil)
+		})
+	})
+	t.Run("everything", func(t *testing.T) {
+		h := &httpx.HandlerGroup{}
+		OnHandlers(h, WithTracerProvider(sdktrace.NewTracerProvider()))
+	})
+}
+
+func TestInstall(t *testing.T) {
+	t.Run("nil HandlerGroup", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			Install(nil)
+		})
+	})
+	t.Run("nil Client", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilClientMsg, func() {
+			InstallOnClient(nil)
+		})
+	})
+	t.Run("everything", func(t *testing.T) {
+		cl := &httpx.Client{}
+
+		p := InstallOnClient(cl)
+
+		assert.NotNil(t, cl.Handlers)
+		assert.NotNil(t, p)
+	})
+	t.Run("twice", func(t *testing.T) {
+		cl := &httpx.Client{}
+
+		p1 := InstallOnClient(cl)
+		p2 := InstallOnClient(cl)
+
+		assert.Same(t, p1, p2)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	t.Run("nil HandlerGroup", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			Remove(nil)
+		})
+	})
+	t.Run("installed", func(t *testing.T) {
+		handlers := OnHandlers(&httpx.HandlerGroup{})
+
+		assert.True(t, Remove(handlers))
+		assert.False(t, Remove(handlers))
+	})
+}
+
+func TestWithHTTPXXRay(t *testing.T) {
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+	}))
+	defer server.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	cl := &httpx.Client{HTTPDoer: server.Client()}
+	xp := httpxxray.InstallOnClient(cl)
+	op := InstallOnClient(cl, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))))
+	ctx, seg := xray.BeginSegment(context.Background(), "root")
+	defer seg.Close(nil)
+	do := func() {
+		p, err := request.NewPlanWithContext(ctx, "GET", server.URL, nil)
+		require.NoError(t, err)
+		_, err = cl.Do(p)
+		require.NoError(t, err)
+	}
+
+	require.NotSame(t, xp, op)
+	do()
+	assert.Equal(t, uint64(1), xp.Stats().ExecutionsTraced)
+	assert.Equal(t, uint64(1), op.Stats().ExecutionsTraced)
+	assert.Len(t, sr.Ended(), 3)
+	require.Len(t, headers, 1)
+	assert.NotEmpty(t, headers[0].Get("X-Amzn-Trace-Id"))
+	assert.NotEmpty(t, headers[0].Get("traceparent"))
+
+	assert.True(t, Remove(cl.Handlers))
+	do()
+	assert.Equal(t, uint64(2), xp.Stats().ExecutionsTraced)
+	assert.Equal(t, uint64(1), op.Stats().ExecutionsTraced)
+
+	assert.True(t, httpxxray.Remove(cl.Handlers))
+	assert.False(t, httpxxray.Remove(cl.Handlers))
+	assert.False(t, Remove(cl.Handlers))
+}
+
+func TestIntegration(t *testing.T) {
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`I so busy`))
+	}))
+	defer server.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	cl := &httpx.Client{
+		HTTPDoer: server.Client(),
+		RetryPolicy: retry.NewPolicy(
+			retry.Times(1).And(retry.StatusCode(429)),
+			retry.DefaultWaiter,
+		),
+	}
+	pl := InstallOnClient(cl,
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))),
+		WithOptions(httpxxray.WithBackoff()))
+	p, err := request.NewPlanWithContext(context.Background(), "GET", server.URL+"/foo?secret=bar", nil)
+	require.NoError(t, err)
+
+	e, err := cl.Do(p)
+
+	require.NotNil(t, e)
+	require.NoError(t, err)
+	spans := sr.Ended()
+	require.Len(t, spans, 6)
+	execSpan := spans[len(spans)-1]
+	assert.Equal(t, trace.SpanKindInternal, execSpan.SpanKind())
+	assert.Equal(t, codes.Error, execSpan.Status().Code)
+	assert.Contains(t, execSpan.Attributes(), attribute.Int("httpx.attempts", 2))
+	assert.Contains(t, execSpan.Attributes(), attribute.Int("httpx.waves", 2))
+	assert.Contains(t, execSpan.Attributes(), attribute.Bool("httpx.throttle", true))
+	require.Len(t, traceparents, 2)
+	for i := 0; i < 2; i++ {
+		s := findSpan(t, spans, fmt.Sprintf("Attempt:%d", i))
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind())
+		assert.Equal(t, execSpan.SpanContext().SpanID(), s.Parent().SpanID())
+		assert.Equal(t, execSpan.SpanContext().TraceID(), s.SpanContext().TraceID())
+		assert.Contains(t, s.Attributes(), attribute.Int("httpx.attempt", i))
+		assert.Contains(t, s.Attributes(), attribute.String("httpx.outcome", "failed"))
+		assert.Contains(t, s.Attributes(), attribute.String("url.full", server.URL+"/foo"))
+		assert.Contains(t, s.Attributes(), attribute.Int("http.response.status_code", 429))
+		assert.Contains(t, s.Attributes(), attribute.String("error.type", "429"))
+		assert.Equal(t, codes.Error, s.Status().Code)
+		assert.NotEmpty(t, s.Events())
+		assert.Contains(t, traceparents[i], s.SpanContext().SpanID().String())
+	}
+	backoff := findSpan(t, spans, "Backoff")
+	assert.Equal(t, execSpan.SpanContext().SpanID(), backoff.Parent().SpanID())
+	stats := pl.Stats()
+	assert.Equal(t, uint64(1), stats.ExecutionsTraced)
+	assert.Equal(t, uint64(2), stats.AttemptsTraced)
+	assert.Empty(t, stats.NotStarted)
+}
+
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, s := range spans {
+		if s.Name() == name {
+			return s
+		}
+	}
+	require.FailNow(t, "span not found", name)
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/gogama/aws-xray-httpx/httpxotel"
+
+// throttleKey marks a span whose classification has the Throttle flag,
+// which has no OpenTelemetry equivalent.
+const throttleKey = attribute.Key("httpx.throttle")
+
+// tracer is the httpxxray.Tracer which records OpenTelemetry spans.
+type tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracer(c Config) *tracer {
+	tp := c.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &tracer{
+		tracer:     tp.Tracer(instrumentationName),
+		propagator: c.Propagator,
+	}
+}
+
+type spanKeyType int
+
+var spanKey = new(spanKeyType)
+
+func (t *tracer) Begin(ctx context.Context, kind httpxxray.SpanKind, name string, start time.Time) (context.Context, httpxxray.Span) {
+	opts := []trace.SpanStartOption{trace.WithSpanKind(spanKind(kind))}
+	if !start.IsZero() {
+		opts = append(opts, trace.WithTimestamp(start))
+	}
+	ctx, s := t.tracer.Start(ctx, name, opts...)
+	sp := &span{span: s}
+	return context.WithValue(ctx, spanKey, sp), sp
+}
+
+func (t *tracer) Get(ctx context.Context) httpxxray.Span {
+	s, _ := ctx.Value(spanKey).(*span)
+	if s == nil {
+		return nil
+	}
+
+	return s
+}
+
+func (t *tracer) TraceHTTP(ctx context.Context) httpxxray.ConnTrace {
+	return connTrace{trace.SpanFromContext(ctx)}
+}
+
+// textMapPropagator returns the propagator used to inject trace context
+// into outgoing requests. It is resolved on each use so that a global
+// propagator set after the plugin is installed still takes effect.
+func (t *tracer) textMapPropagator() propagation.TextMapPropagator {
+	if t.propagator != nil {
+		return t.propagator
+	}
+
+	// The global propagator is a no-op, with no fields, until the
+	// application sets one. Falling back to W3C Trace Context ensures
+	// the trace is always propagated downstream.
+	p := otel.GetTextMapPropagator()
+	if len(p.Fields()) == 0 {
+		return propagation.TraceContext{}
+	}
+
+	return p
+}
+
+// textMapInjector is the default httpxxray.Propagator of the plugin. It
+// injects the attempt span in the request context using the tracer's
+// OpenTelemetry TextMapPropagator.
+type textMapInjector struct {
+	tracer *tracer
+}
+
+func (i textMapInjector) Inject(req *http.Request, _ httpxxray.TraceContext) {
+	i.tracer.textMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// spanKind maps an httpxxray span kind onto an OpenTelemetry span kind.
+// Each request attempt is an outgoing HTTP request, so it is a client
+// span, while the other spans are internal.
+func spanKind(kind httpxxray.SpanKind) trace.SpanKind {
+	if kind == httpxxray.AttemptSpan {
+		return trace.SpanKindClient
+	}
+
+	return trace.SpanKindInternal
+}
+
+type span struct {
+	span trace.Span
+
+	lock   sync.Mutex
+	status int
+}
+
+func (s *span) SetHTTPRequest(method, rawURL string) {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.URLFull(rawURL),
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		attrs = append(attrs, serverAttributes(u)...)
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+func (s *span) SetHTTPResponse(status, contentLength int) {
+	s.lock.Lock()
+	s.status = status
+	s.lock.Unlock()
+
+	s.span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if contentLength > 0 {
+		s.span.SetAttributes(semconv.HTTPResponseBodySize(contentLength))
+	}
+}
+
+// SetAWS records the AWS call using the attributes of the OpenTelemetry
+// semantic conventions for AWS SDK calls.
+func (s *span) SetAWS(call httpxxray.AWSCall) {
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("aws-api")}
+	if call.Service != "" {
+		attrs = append(attrs, semconv.RPCService(call.Service))
+	}
+	if call.Operation != "" {
+		attrs = append(attrs, semconv.RPCMethod(call.Operation))
+	}
+	if call.Region != "" {
+		attrs = append(attrs, semconv.CloudRegion(call.Region))
+	}
+	if call.RequestID != "" {
+		attrs = append(attrs, semconv.AWSRequestID(call.RequestID))
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+func (s *span) AddAnnotation(key string, value interface{}) {
+	s.span.SetAttributes(attributeOf(key, value))
+}
+
+func (s *span) AddMetadata(namespace, key string, value interface{}) {
+	s.span.SetAttributes(attributeOf(namespace+"."+key, value))
+}
+
+// TraceContext returns the span's OpenTelemetry trace context in X-Ray
+// format, so that httpxxray propagators such as XRayPropagator and
+// W3CPropagator continue the OpenTelemetry trace.
+func (s *span) TraceContext() httpxxray.TraceContext {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return httpxxray.TraceContext{}
+	}
+
+	traceID := sc.TraceID().String()
+	return httpxxray.TraceContext{
+		TraceID:  "1-" + traceID[:8] + "-" + traceID[8:],
+		ParentID: sc.SpanID().String(),
+		Sampled:  sc.IsSampled(),
+	}
+}
+
+func (s *span) Close(err error, c httpxxray.Classification) {
+	defer s.span.End()
+
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	if c.Throttle {
+		s.span.SetAttributes(throttleKey.Bool(true))
+	}
+	if !c.Fault && !c.Error {
+		return
+	}
+
+	if err != nil {
+		s.span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	s.lock.Lock()
+	status := s.status
+	s.lock.Unlock()
+	if status != 0 {
+		s.span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+	}
+	s.span.SetStatus(codes.Error, "")
+}
+
+func attributeOf(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+func serverAttributes(u *url.URL) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.ServerAddress(u.Hostname())}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		switch u.Scheme {
+		case "http":
+			port = 80
+		case "https":
+			port = 443
+		default:
+			return attrs
+		}
+	}
+	return append(attrs, semconv.ServerPort(port))
+}
+
+// connTrace records the low-level HTTP operations of a request attempt
+// as events on the attempt span.
+type connTrace struct {
+	span trace.Span
+}
+
+func (t connTrace) ClientTrace() *httptrace.ClientTrace {
+	s := t.span
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			s.AddEvent("http.get_conn", trace.WithAttributes(attribute.String("host_port", hostPort)))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			s.AddEvent("http.dns_start", trace.WithAttributes(attribute.String("host", info.Host)))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			s.AddEvent("http.dns_done", trace.WithAttributes(errorAttributes(info.Err,
+				attribute.StringSlice("addrs", ipAddrs(info.Addrs)))...))
+		},
+		ConnectStart: func(network, addr string) {
+			s.AddEvent("http.connect_start", trace.WithAttributes(
+				attribute.String("network", network), attribute.String("addr", addr)))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			s.AddEvent("http.connect_done", trace.WithAttributes(errorAttributes(err,
+				attribute.String("network", network), attribute.String("addr", addr))...))
+		},
+		TLSHandshakeStart: func() {
+			s.AddEvent("http.tls_handshake_start")
+		},
+		TLSHandshakeDone: func(connState tls.ConnectionState, err error) {
+			s.AddEvent("http.tls_handshake_done", trace.WithAttributes(errorAttributes(err,
+				attribute.Bool("resumed", connState.DidResume),
+				attribute.String("negotiated_protocol", connState.NegotiatedProtocol))...))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.AddEvent("http.got_conn", trace.WithAttributes(
+				attribute.Bool("reused", info.Reused), attribute.Bool("was_idle", info.WasIdle)))
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			s.AddEvent("http.wrote_request", trace.WithAttributes(errorAttributes(info.Err)...))
+		},
+		GotFirstResponseByte: func() {
+			s.AddEvent("http.got_first_response_byte")
+		},
+	}
+}
+
+func errorAttributes(err error, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	if err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	return attrs
+}
+
+func ipAddrs(addrs []net.IPAddr) []string {
+	s := make([]string, len(addrs))
+	for i := range addrs {
+		s[i] = addrs[i].String()
+	}
+	return s
+}
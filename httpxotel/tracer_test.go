@@ -0,0 +1,248 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	t.Run("Begin", func(t *testing.T) {
+		tr, sr := newTestTracer(Config{})
+		start := time.Unix(1600000000, 0)
+
+		ctx, s := tr.Begin(context.Background(), httpxxray.ExecutionSpan, "foo.com", time.Time{})
+		_, b := tr.Begin(ctx, httpxxray.BackoffSpan, "Backoff", start)
+		_, a := tr.Begin(ctx, httpxxray.AttemptSpan, "Attempt:0", time.Time{})
+		a.Close(nil, httpxxray.Classification{})
+		b.Close(nil, httpxxray.Classification{})
+		s.Close(nil, httpxxray.Classification{})
+
+		spans := sr.Ended()
+		require.Len(t, spans, 3)
+		assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+		assert.Equal(t, trace.SpanKindInternal, spans[1].SpanKind())
+		assert.Equal(t, start, spans[1].StartTime())
+		assert.Equal(t, trace.SpanKindInternal, spans[2].SpanKind())
+		assert.Equal(t, spans[2].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	})
+	t.Run("Get", func(t *testing.T) {
+		tr, _ := newTestTracer(Config{})
+		foreignCtx, foreign := sdktrace.NewTracerProvider().Tracer("foreign").Start(context.Background(), "foreign")
+		defer foreign.End()
+
+		assert.Nil(t, tr.Get(context.Background()))
+		assert.Nil(t, tr.Get(foreignCtx), "spans not begun by the tracer are ignored")
+		ctx, s := tr.Begin(foreignCtx, httpxxray.ExecutionSpan, "foo.com", time.Time{})
+		defer s.Close(nil, httpxxray.Classification{})
+		assert.Same(t, s, tr.Get(ctx))
+	})
+	t.Run("TraceHTTP", func(t *testing.T) {
+		tr, sr := newTestTracer(Config{})
+		ctx, s := tr.Begin(context.Background(), httpxxray.AttemptSpan, "Attempt:0", time.Time{})
+
+		ct := tr.TraceHTTP(ctx).ClientTrace()
+		ct.GetConn("foo.com:443")
+		ct.ConnectDone("tcp", "1.2.3.4:443", errors.New("refused"))
+		s.Close(nil, httpxxray.Classification{})
+
+		require.Len(t, sr.Ended(), 1)
+		events := sr.Ended()[0].Events()
+		require.Len(t, events, 2)
+		assert.Equal(t, "http.get_conn", events[0].Name)
+		assert.Equal(t, "http.connect_done", events[1].Name)
+		assert.Contains(t, events[1].Attributes, attribute.String("error", "refused"))
+	})
+	t.Run("textMapInjector", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			propagator propagation.TextMapPropagator
+			header     string
+		}{
+			{"default", nil, "traceparent"},
+			{"explicit", propagation.Baggage{}, ""},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				tr, _ := newTestTracer(Config{Propagator: testCase.propagator})
+				ctx, s := tr.Begin(context.Background(), httpxxray.AttemptSpan, "Attempt:0", time.Time{})
+				defer s.Close(nil, httpxxray.Classification{})
+				req := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+
+				textMapInjector{tr}.Inject(req, s.TraceContext())
+
+				if testCase.header != "" {
+					assert.NotEmpty(t, req.Header.Get(testCase.header))
+				} else {
+					assert.Empty(t, req.Header)
+				}
+			})
+		}
+	})
+}
+
+func TestSpan(t *testing.T) {
+	t.Run("SetHTTPRequest", func(t *testing.T) {
+		s, sr := newTestSpan()
+
+		s.SetHTTPRequest("PUT", "https://foo.com/bar")
+		s.Close(nil, httpxxray.Classification{})
+
+		attrs := sr.Ended()[0].Attributes()
+		assert.Contains(t, attrs, attribute.String("http.request.method", "PUT"))
+		assert.Contains(t, attrs, attribute.String("url.full", "https://foo.com/bar"))
+		assert.Contains(t, attrs, attribute.String("server.address", "foo.com"))
+		assert.Contains(t, attrs, attribute.Int("server.port", 443))
+	})
+	t.Run("SetHTTPResponse", func(t *testing.T) {
+		s, sr := newTestSpan()
+
+		s.SetHTTPResponse(200, 10)
+		s.Close(nil, httpxxray.Classification{})
+
+		attrs := sr.Ended()[0].Attributes()
+		assert.Contains(t, attrs, attribute.Int("http.response.status_code", 200))
+		assert.Contains(t, attrs, attribute.Int("http.response.body.size", 10))
+	})
+	t.Run("TraceContext", func(t *testing.T) {
+		s, _ := newTestSpan()
+		defer s.Close(nil, httpxxray.Classification{})
+		sc := s.(*span).span.SpanContext()
+		traceID := sc.TraceID().String()
+
+		tc := s.TraceContext()
+
+		assert.Equal(t, "1-"+traceID[:8]+"-"+traceID[8:], tc.TraceID)
+		assert.Equal(t, sc.SpanID().String(), tc.ParentID)
+		assert.True(t, tc.Sampled)
+		req := &http.Request{Header: http.Header{}}
+		httpxxray.W3CPropagator.Inject(req, tc)
+		assert.Equal(t, "00-"+traceID+"-"+tc.ParentID+"-01", req.Header.Get("traceparent"))
+	})
+	t.Run("SetAWS", func(t *testing.T) {
+		s, sr := newTestSpan()
+		require.Implements(t, (*httpxxray.AWSSpan)(nil), s)
+
+		s.(httpxxray.AWSSpan).SetAWS(httpxxray.AWSCall{Service: "dynamodb", Region: "us-east-1", Operation: "GetItem", RequestID: "abc"})
+		s.Close(nil, httpxxray.Classification{})
+
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("rpc.system", "aws-api"),
+			attribute.String("rpc.service", "dynamodb"),
+			attribute.String("rpc.method", "GetItem"),
+			attribute.String("cloud.region", "us-east-1"),
+			attribute.String("aws.request_id", "abc"),
+		}, sr.Ended()[0].Attributes())
+	})
+	t.Run("AddAnnotation and AddMetadata", func(t *testing.T) {
+		s, sr := newTestSpan()
+
+		s.AddAnnotation("httpx_attempts", 3)
+		s.AddMetadata("httpx", "timeout_ms", int64(5000))
+		s.AddMetadata("httpx", "plan_timeout", true)
+		s.AddMetadata("httpx_headers", "response_x_cache", "HIT")
+		s.Close(nil, httpxxray.Classification{})
+
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.Int("httpx_attempts", 3),
+			attribute.Int64("httpx.timeout_ms", 5000),
+			attribute.Bool("httpx.plan_timeout", true),
+			attribute.String("httpx_headers.response_x_cache", "HIT"),
+		}, sr.Ended()[0].Attributes())
+	})
+	t.Run("Close", func(t *testing.T) {
+		testCases := []struct {
+			name   string
+			status int
+			err    error
+			cls    httpxxray.Classification
+			code   codes.Code
+			desc   string
+			attrs  []attribute.KeyValue
+		}{
+			{"success", 200, nil, httpxxray.Classification{}, codes.Unset, "", nil},
+			{"error not classified", 0, errors.New("foo"), httpxxray.Classification{}, codes.Unset, "", nil},
+			{"fault[error]", 0, errors.New("foo"), httpxxray.Classification{Fault: true}, codes.Error, "foo", nil},
+			{"fault[status]", 503, nil, httpxxray.Classification{Fault: true}, codes.Error, "", []attribute.KeyValue{attribute.String("error.type", "503")}},
+			{"throttle", 429, nil, httpxxray.Classification{Error: true, Throttle: true}, codes.Error, "", []attribute.KeyValue{attribute.String("error.type", "429"), attribute.Bool("httpx.throttle", true)}},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				s, sr := newTestSpan()
+				if testCase.status != 0 {
+					s.SetHTTPResponse(testCase.status, 0)
+				}
+
+				s.Close(testCase.err, testCase.cls)
+
+				require.Len(t, sr.Ended(), 1)
+				ended := sr.Ended()[0]
+				assert.Equal(t, testCase.code, ended.Status().Code)
+				assert.Equal(t, testCase.desc, ended.Status().Description)
+				for _, attr := range testCase.attrs {
+					assert.Contains(t, ended.Attributes(), attr)
+				}
+				assert.Equal(t, testCase.err != nil, len(ended.Events()) == 1)
+			})
+		}
+	})
+}
+
+func TestServerAttributes(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected []attribute.KeyValue
+	}{
+		{"http://foo.com", []attribute.KeyValue{
+			attribute.String("server.address", "foo.com"),
+			attribute.Int("server.port", 80),
+		}},
+		{"https://foo.com", []attribute.KeyValue{
+			attribute.String("server.address", "foo.com"),
+			attribute.Int("server.port", 443),
+		}},
+		{"https://foo.com:8443", []attribute.KeyValue{
+			attribute.String("server.address", "foo.com"),
+			attribute.Int("server.port", 8443),
+		}},
+		{"ftp://foo.com", []attribute.KeyValue{
+			attribute.String("server.address", "foo.com"),
+		}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			u, err := url.Parse(testCase.url)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, serverAttributes(u))
+		})
+	}
+}
+
+func newTestTracer(c Config) (*tracer, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	c.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	return newTracer(c), sr
+}
+
+func newTestSpan() (httpxxray.Span, *tracetest.SpanRecorder) {
+	tr, sr := newTestTracer(Config{})
+	_, s := tr.Begin(context.Background(), httpxxray.AttemptSpan, "Attempt:0", time.Time{})
+	return s, sr
+}
@@ -0,0 +1,252 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// An AWSCall describes a request to an AWS service endpoint, as
+// recorded in the aws block of X-Ray subsegments. Fields which cannot be
+// derived from the request and response are empty.
+type AWSCall struct {
+	// Service is the AWS service name used to sign requests to the
+	// endpoint, which is usually also the endpoint prefix, for example
+	// "dynamodb", "s3", or "execute-api" for API Gateway.
+	Service string
+
+	// Region is the AWS region of the endpoint, for example
+	// "us-east-1".
+	Region string
+
+	// Operation is the name of the AWS API operation, for example
+	// "GetItem" or "PutObject".
+	Operation string
+
+	// RequestID is the AWS request ID returned by the service.
+	RequestID string
+}
+
+// awsCall describes the request req, and its response resp, which may
+// be nil, as a call to an AWS service. It reports false if req is not
+// sent to an AWS service endpoint, that is to a host in the domain
+// amazonaws.com or amazonaws.com.cn.
+//
+// The service and region are taken from the SigV4 credential scope, if
+// the request is signed, and otherwise from the endpoint host name. The
+// operation is taken from the X-Amz-Target header used by JSON protocol
+// services, the Action query parameter used by query protocol services,
+// or for S3, from the method and path.
+func awsCall(req *http.Request, resp *http.Response) (AWSCall, bool) {
+	labels, ok := awsEndpointLabels(req.URL.Hostname())
+	if !ok {
+		return AWSCall{}, false
+	}
+
+	var call AWSCall
+	call.Service, call.Region = awsEndpointServiceRegion(labels)
+	if service, region := credentialScope(req); service != "" {
+		call.Service, call.Region = service, region
+	}
+	call.Operation = awsOperation(req, call.Service, labels)
+	if resp != nil {
+		call.RequestID = resp.Header.Get("X-Amzn-RequestId")
+		if call.RequestID == "" {
+			call.RequestID = resp.Header.Get("X-Amz-Request-Id")
+		}
+	}
+	return call, true
+}
+
+var awsDomains = []string{".amazonaws.com", ".amazonaws.com.cn"}
+
+// awsEndpointLabels returns the labels of host preceding the AWS domain
+// name, for example ["dynamodb", "us-east-1"] for the host
+// "dynamodb.us-east-1.amazonaws.com".
+func awsEndpointLabels(host string) ([]string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range awsDomains {
+		if strings.HasSuffix(host, domain) && len(host) > len(domain) {
+			return strings.Split(host[:len(host)-len(domain)], "."), true
+		}
+	}
+	return nil, false
+}
+
+var awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+$`)
+
+// awsEndpointServiceRegion derives the service and region from the
+// labels of an AWS endpoint host name. Endpoint host names usually have
+// the form "service.region", optionally preceded by a resource, such as
+// "bucket.s3.region" or "api-id.execute-api.region". A few services put
+// the service after the region, as in "domain.region.es", and global
+// endpoints have no region, as in "sts" or the legacy "s3-region".
+func awsEndpointServiceRegion(labels []string) (service, region string) {
+	r := -1
+	for i := range labels {
+		if awsRegionPattern.MatchString(labels[i]) {
+			r = i
+			break
+		}
+	}
+
+	switch {
+	case r < 0:
+		service = labels[len(labels)-1]
+		if strings.HasPrefix(service, "s3-") && awsRegionPattern.MatchString(service[3:]) {
+			service, region = "s3", service[3:]
+		}
+	case r+1 < len(labels):
+		service, region = labels[len(labels)-1], labels[r]
+	case r > 0:
+		service, region = labels[r-1], labels[r]
+		if service == "dualstack" && r > 1 {
+			service = labels[r-2]
+		}
+	default:
+		region = labels[r]
+	}
+
+	return strings.TrimSuffix(service, "-fips"), region
+}
+
+// credentialScope returns the service and region from the SigV4
+// credential scope of req, which is in the Authorization header of a
+// signed request, or in the X-Amz-Credential query parameter of a
+// pre-signed URL.
+func credentialScope(req *http.Request) (service, region string) {
+	credential := req.URL.Query().Get("X-Amz-Credential")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-") {
+		if i := strings.Index(auth, "Credential="); i >= 0 {
+			credential = auth[i+len("Credential="):]
+			if j := strings.IndexAny(credential, ", "); j >= 0 {
+				credential = credential[:j]
+			}
+		}
+	}
+
+	// The scope is access-key/date/region/service/aws4_request.
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return "", ""
+	}
+
+	return parts[3], parts[2]
+}
+
+func awsOperation(req *http.Request, service string, labels []string) string {
+	if target := req.Header.Get("X-Amz-Target"); target != "" {
+		return target[strings.LastIndexByte(target, '.')+1:]
+	}
+	query := req.URL.Query()
+	if action := query.Get("Action"); action != "" {
+		return action
+	}
+	if service == "s3" {
+		return s3Operation(req.Method, req.URL, query, labels)
+	}
+
+	return ""
+}
+
+// s3Query lists the query parameters which do not change the S3
+// operation: the parameters of ListObjects and ListObjectsV2, object
+// versions, and the authentication parameters of pre-signed URLs. Any
+// other parameter selects a sub-resource, such as tagging, so the
+// operation is not derived.
+var s3Query = map[string]bool{
+	"list-type":            true,
+	"prefix":               true,
+	"delimiter":            true,
+	"max-keys":             true,
+	"marker":               true,
+	"continuation-token":   true,
+	"start-after":          true,
+	"encoding-type":        true,
+	"fetch-owner":          true,
+	"versionid":            true,
+	"x-amz-algorithm":      true,
+	"x-amz-credential":     true,
+	"x-amz-date":           true,
+	"x-amz-expires":        true,
+	"x-amz-signedheaders":  true,
+	"x-amz-signature":      true,
+	"x-amz-security-token": true,
+	"awsaccesskeyid":       true,
+	"expires":              true,
+	"signature":            true,
+}
+
+// s3Operation derives the S3 operation from the method and path of a
+// request. With virtual hosted-style URLs, such as
+// "bucket.s3.us-east-1.amazonaws.com/key", the bucket is in the host
+// name, while with path-style URLs, such as
+// "s3.us-east-1.amazonaws.com/bucket/key", it is the first path
+// segment.
+func s3Operation(method string, u *url.URL, query url.Values, labels []string) string {
+	for k := range query {
+		if !s3Query[strings.ToLower(k)] {
+			return ""
+		}
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	bucket := s3BucketInHost(labels)
+	if !bucket && path != "" {
+		bucket = true
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			path = path[i+1:]
+		} else {
+			path = ""
+		}
+	}
+
+	switch {
+	case path != "":
+		switch method {
+		case http.MethodGet:
+			return "GetObject"
+		case http.MethodPut:
+			return "PutObject"
+		case http.MethodDelete:
+			return "DeleteObject"
+		case http.MethodHead:
+			return "HeadObject"
+		}
+	case bucket:
+		switch method {
+		case http.MethodGet:
+			if query.Get("list-type") == "2" {
+				return "ListObjectsV2"
+			}
+			return "ListObjects"
+		case http.MethodPut:
+			return "CreateBucket"
+		case http.MethodDelete:
+			return "DeleteBucket"
+		case http.MethodHead:
+			return "HeadBucket"
+		}
+	case method == http.MethodGet:
+		return "ListBuckets"
+	}
+
+	return ""
+}
+
+// s3BucketInHost reports whether the labels of an S3 endpoint host name
+// start with a bucket name, that is whether a label precedes the "s3"
+// or legacy "s3-region" label.
+func s3BucketInHost(labels []string) bool {
+	for i := range labels {
+		if labels[i] == "s3" || strings.HasPrefix(labels[i], "s3-") {
+			return i > 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSCall(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		header http.Header
+		resp   *http.Response
+		call   AWSCall
+		ok     bool
+	}{
+		{
+			name: "Not AWS",
+			url:  "https://example.com/",
+		},
+		{
+			name: "Not AWS[Suffix lookalike]",
+			url:  "https://notamazonaws.com/",
+		},
+		{
+			name:   "DynamoDB",
+			method: "POST",
+			url:    "https://dynamodb.us-east-1.amazonaws.com/",
+			header: http.Header{"X-Amz-Target": {"DynamoDB_20120810.GetItem"}},
+			resp:   &http.Response{Header: http.Header{"X-Amzn-Requestid": {"ABC123"}}},
+			call:   AWSCall{Service: "dynamodb", Region: "us-east-1", Operation: "GetItem", RequestID: "ABC123"},
+			ok:     true,
+		},
+		{
+			name: "SQS[Query protocol]",
+			url:  "https://sqs.eu-west-1.amazonaws.com/123456789012/queue?Action=ReceiveMessage",
+			call: AWSCall{Service: "sqs", Region: "eu-west-1", Operation: "ReceiveMessage"},
+			ok:   true,
+		},
+		{
+			name: "STS[Global]",
+			url:  "https://sts.amazonaws.com/?Action=GetCallerIdentity",
+			call: AWSCall{Service: "sts", Operation: "GetCallerIdentity"},
+			ok:   true,
+		},
+		{
+			name: "API Gateway",
+			url:  "https://a1b2c3.execute-api.ap-southeast-2.amazonaws.com/prod/things",
+			resp: &http.Response{Header: http.Header{"X-Amzn-Requestid": {"r-1"}}},
+			call: AWSCall{Service: "execute-api", Region: "ap-southeast-2", RequestID: "r-1"},
+			ok:   true,
+		},
+		{
+			name: "China",
+			url:  "https://lambda.cn-north-1.amazonaws.com.cn/2015-03-31/functions",
+			call: AWSCall{Service: "lambda", Region: "cn-north-1"},
+			ok:   true,
+		},
+		{
+			name: "OpenSearch[Service after region]",
+			url:  "https://search-domain.us-west-2.es.amazonaws.com/_search",
+			call: AWSCall{Service: "es", Region: "us-west-2"},
+			ok:   true,
+		},
+		{
+			name: "FIPS",
+			url:  "https://kms-fips.us-gov-west-1.amazonaws.com/",
+			call: AWSCall{Service: "kms", Region: "us-gov-west-1"},
+			ok:   true,
+		},
+		{
+			name: "S3[Virtual hosted-style GetObject, pre-signed]",
+			url:  "https://bucket.s3.amazonaws.com/a/b.txt?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKID%2F20260101%2Fus-west-2%2Fs3%2Faws4_request&X-Amz-Signature=abc",
+			resp: &http.Response{Header: http.Header{"X-Amz-Request-Id": {"S3ID"}}},
+			call: AWSCall{Service: "s3", Region: "us-west-2", Operation: "GetObject", RequestID: "S3ID"},
+			ok:   true,
+		},
+		{
+			name:   "S3[Path-style PutObject]",
+			method: "PUT",
+			url:    "https://s3.us-east-2.amazonaws.com/bucket/key",
+			call:   AWSCall{Service: "s3", Region: "us-east-2", Operation: "PutObject"},
+			ok:     true,
+		},
+		{
+			name: "S3[Path-style ListObjectsV2]",
+			url:  "https://s3.dualstack.us-east-2.amazonaws.com/bucket?list-type=2&prefix=a",
+			call: AWSCall{Service: "s3", Region: "us-east-2", Operation: "ListObjectsV2"},
+			ok:   true,
+		},
+		{
+			name:   "S3[Legacy regional HeadBucket]",
+			method: "HEAD",
+			url:    "https://bucket.s3-eu-west-1.amazonaws.com/",
+			call:   AWSCall{Service: "s3", Region: "eu-west-1", Operation: "HeadBucket"},
+			ok:     true,
+		},
+		{
+			name: "S3[ListBuckets]",
+			url:  "https://s3.amazonaws.com/",
+			call: AWSCall{Service: "s3", Operation: "ListBuckets"},
+			ok:   true,
+		},
+		{
+			name: "S3[Sub-resource]",
+			url:  "https://bucket.s3.us-east-1.amazonaws.com/key?tagging",
+			call: AWSCall{Service: "s3", Region: "us-east-1"},
+			ok:   true,
+		},
+		{
+			name:   "SigV4[Custom host prefix]",
+			method: "POST",
+			url:    "https://vpce-123.execute-api.us-east-1.vpce.amazonaws.com/",
+			header: http.Header{"Authorization": {"AWS4-HMAC-SHA256 Credential=AKID/20260101/us-east-1/execute-api/aws4_request, SignedHeaders=host, Signature=abc"}},
+			call:   AWSCall{Service: "execute-api", Region: "us-east-1"},
+			ok:     true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			method := testCase.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, testCase.url, nil)
+			for k, v := range testCase.header {
+				req.Header[k] = v
+			}
+
+			call, ok := awsCall(req, testCase.resp)
+
+			assert.Equal(t, testCase.ok, ok)
+			assert.Equal(t, testCase.call, call)
+		})
+	}
+}
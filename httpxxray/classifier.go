@@ -0,0 +1,174 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import "github.com/gogama/httpx/request"
+
+// A Classification holds the X-Ray fault, error, and throttle flags for
+// a subsegment.
+//
+// In X-Ray's terms, Fault indicates a server-side problem (typically
+// an HTTP 5XX status code or a failure to get any response at all),
+// Error indicates a client-side problem (typically an HTTP 4XX status
+// code), and Throttle indicates the request was rejected due to rate
+// limiting (typically HTTP 429). Throttle is normally accompanied by
+// Error.
+type Classification struct {
+	Fault    bool
+	Error    bool
+	Throttle bool
+}
+
+// A Classifier decides the X-Ray fault, error, and throttle flags for
+// the attempt and execution subsegments.
+//
+// The classifier is consulted when each request attempt ends (during
+// the httpx.AfterAttempt event) to classify the attempt subsegment,
+// and again when the plan execution ends (during the
+// httpx.AfterExecutionEnd event) to classify the execution subsegment.
+// In each case the execution's Response, Err and Body fields describe
+// the outcome being classified.
+//
+// The flags returned by the classifier are the final word on the
+// subsegment's flags. In particular, if the execution has a non-nil
+// error, the error is still recorded as an exception in the
+// subsegment's cause, but the subsegment is only marked as a fault if
+// the classifier says so.
+//
+// Implementations of Classifier must be safe for concurrent use by
+// multiple goroutines.
+type Classifier interface {
+	Classify(e *request.Execution) Classification
+}
+
+// The ClassifierFunc type is an adapter to allow the use of ordinary
+// functions as classifiers. If f is a function with appropriate
+// signature, then ClassifierFunc(f) is a Classifier that calls f.
+type ClassifierFunc func(e *request.Execution) Classification
+
+// Classify calls f(e).
+func (f ClassifierFunc) Classify(e *request.Execution) Classification {
+	return f(e)
+}
+
+// DefaultClassifier is the Classifier used if no other classifier is
+// configured. It emulates the HTTP response handling logic of the
+// Capture closure in the AWS X-Ray SDK for Go:
+//
+// • any non-nil execution error is a fault;
+//
+// • HTTP status codes 400-499 are errors, and 429 is additionally a
+// throttle; and
+//
+// • HTTP status codes 500-599 are faults.
+//
+// As an exception to the above, a timeout (either an attempt timeout
+// or a plan timeout) is always classified as a fault only, regardless
+// of the status code of any partially-read response. This ensures a
+// timed out attempt is classified the same way whether the timeout
+// occurred while waiting for the response headers or while reading
+// the response body.
+var DefaultClassifier Classifier = ClassifierFunc(classifyDefault)
+
+// NewStatusClassifier returns a Classifier which classifies responses
+// having one of the HTTP status codes in overrides using the matching
+// Classification, and delegates all other cases to base. A nil base is
+// interpreted as DefaultClassifier.
+//
+// The overrides only apply when the execution has no error. The map is
+// copied, so later changes to it have no effect on the returned
+// classifier.
+//
+// For example, to treat 404 as a normal response and 503 as throttling:
+//
+//	httpxxray.NewStatusClassifier(nil, map[int]httpxxray.Classification{
+//		404: {},
+//		503: {Error: true, Throttle: true},
+//	})
+func NewStatusClassifier(base Classifier, overrides map[int]Classification) Classifier {
+	if base == nil {
+		base = DefaultClassifier
+	}
+	o := make(map[int]Classification, len(overrides))
+	for k, v := range overrides {
+		o[k] = v
+	}
+	return ClassifierFunc(func(e *request.Execution) Classification {
+		if e.Err == nil && e.Response != nil {
+			if c, ok := o[e.Response.StatusCode]; ok {
+				return c
+			}
+		}
+		return base.Classify(e)
+	})
+}
+
+// TreatAsSuccess returns a Classifier which classifies responses having
+// any of the given HTTP status codes as successful (no flags set), and
+// delegates all other cases to base. A nil base is interpreted as
+// DefaultClassifier.
+func TreatAsSuccess(base Classifier, statusCodes ...int) Classifier {
+	return NewStatusClassifier(base, statusOverrides(Classification{}, statusCodes))
+}
+
+// TreatAsThrottle returns a Classifier which classifies responses
+// having any of the given HTTP status codes as throttled (Error and
+// Throttle set), and delegates all other cases to base. A nil base is
+// interpreted as DefaultClassifier.
+func TreatAsThrottle(base Classifier, statusCodes ...int) Classifier {
+	return NewStatusClassifier(base, statusOverrides(Classification{Error: true, Throttle: true}, statusCodes))
+}
+
+// TreatTimeoutAs returns a Classifier which classifies every execution
+// whose error is a timeout (see request.Execution.Timeout) using c, and
+// delegates all other cases to base. A nil base is interpreted as
+// DefaultClassifier.
+//
+// For example, to classify timeouts as errors rather than faults:
+//
+//	httpxxray.TreatTimeoutAs(nil, httpxxray.Classification{Error: true})
+func TreatTimeoutAs(base Classifier, c Classification) Classifier {
+	if base == nil {
+		base = DefaultClassifier
+	}
+	return ClassifierFunc(func(e *request.Execution) Classification {
+		if e.Timeout() {
+			return c
+		}
+		return base.Classify(e)
+	})
+}
+
+func statusOverrides(c Classification, statusCodes []int) map[int]Classification {
+	m := make(map[int]Classification, len(statusCodes))
+	for _, statusCode := range statusCodes {
+		m[statusCode] = c
+	}
+	return m
+}
+
+func classifyDefault(e *request.Execution) (c Classification) {
+	if e.Err != nil {
+		c.Fault = true
+		if e.Timeout() {
+			return
+		}
+	}
+
+	// Emulate HTTP header handling logic within Capture closure in X-Ray
+	// SDK: xray/client.go.
+	statusCode := e.StatusCode()
+	switch statusCode / 100 {
+	case 4:
+		c.Error = true
+		if statusCode == 429 {
+			c.Throttle = true
+		}
+	case 5:
+		c.Fault = true
+	}
+
+	return
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultClassifier(t *testing.T) {
+	t.Run("No response", func(t *testing.T) {
+		c := DefaultClassifier.Classify(&request.Execution{})
+
+		assert.Equal(t, Classification{}, c)
+	})
+	t.Run("Error without response", func(t *testing.T) {
+		c := DefaultClassifier.Classify(&request.Execution{Err: errors.New("foo")})
+
+		assert.Equal(t, Classification{Fault: true}, c)
+	})
+	t.Run("OK", func(t *testing.T) {
+		c := DefaultClassifier.Classify(executionWithStatus(200))
+
+		assert.Equal(t, Classification{}, c)
+	})
+	t.Run("Error.4XX", func(t *testing.T) {
+		statusCodes := []int{400, 401, 403, 404, 405, 406, 409}
+		for _, statusCode := range statusCodes {
+			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
+				c := DefaultClassifier.Classify(executionWithStatus(statusCode))
+
+				assert.Equal(t, Classification{Error: true}, c)
+			})
+		}
+	})
+	t.Run("Error.429", func(t *testing.T) {
+		c := DefaultClassifier.Classify(executionWithStatus(429))
+
+		assert.Equal(t, Classification{Error: true, Throttle: true}, c)
+	})
+	t.Run("Fault.5XX", func(t *testing.T) {
+		statusCodes := []int{500, 502, 503, 504, 505}
+		for _, statusCode := range statusCodes {
+			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
+				c := DefaultClassifier.Classify(executionWithStatus(statusCode))
+
+				assert.Equal(t, Classification{Fault: true}, c)
+			})
+		}
+	})
+	t.Run("Error reading body", func(t *testing.T) {
+		e := executionWithStatus(404)
+		e.Err = errors.New("bar")
+
+		c := DefaultClassifier.Classify(e)
+
+		assert.Equal(t, Classification{Fault: true, Error: true}, c)
+	})
+}
+
+func TestDefaultClassifier_Timeout(t *testing.T) {
+	t.Run("No response", func(t *testing.T) {
+		e := &request.Execution{Err: timeoutErr}
+
+		assert.Equal(t, Classification{Fault: true}, DefaultClassifier.Classify(e))
+	})
+	t.Run("Partial response", func(t *testing.T) {
+		statusCodes := []int{200, 404, 429, 503}
+		for _, statusCode := range statusCodes {
+			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
+				e := executionWithStatus(statusCode)
+				e.Err = timeoutErr
+
+				assert.Equal(t, Classification{Fault: true}, DefaultClassifier.Classify(e))
+			})
+		}
+	})
+}
+
+func TestNewStatusClassifier(t *testing.T) {
+	t.Run("nil base", func(t *testing.T) {
+		cl := NewStatusClassifier(nil, nil)
+
+		assert.Equal(t, Classification{Fault: true}, cl.Classify(executionWithStatus(503)))
+	})
+	t.Run("overrides", func(t *testing.T) {
+		overrides := map[int]Classification{
+			404: {},
+			503: {Error: true, Throttle: true},
+		}
+		cl := NewStatusClassifier(DefaultClassifier, overrides)
+		overrides[500] = Classification{}
+
+		assert.Equal(t, Classification{}, cl.Classify(executionWithStatus(404)))
+		assert.Equal(t, Classification{Error: true, Throttle: true}, cl.Classify(executionWithStatus(503)))
+		assert.Equal(t, Classification{Fault: true}, cl.Classify(executionWithStatus(500)))
+		assert.Equal(t, Classification{Error: true}, cl.Classify(executionWithStatus(400)))
+	})
+	t.Run("error", func(t *testing.T) {
+		cl := NewStatusClassifier(nil, map[int]Classification{404: {}})
+		e := executionWithStatus(404)
+		e.Err = errors.New("baz")
+
+		assert.Equal(t, Classification{Fault: true, Error: true}, cl.Classify(e))
+	})
+}
+
+func TestTreatAsSuccess(t *testing.T) {
+	cl := TreatAsSuccess(nil, 404, 410)
+
+	assert.Equal(t, Classification{}, cl.Classify(executionWithStatus(404)))
+	assert.Equal(t, Classification{}, cl.Classify(executionWithStatus(410)))
+	assert.Equal(t, Classification{Error: true}, cl.Classify(executionWithStatus(409)))
+}
+
+func TestTreatAsThrottle(t *testing.T) {
+	cl := TreatAsThrottle(nil, 503)
+
+	assert.Equal(t, Classification{Error: true, Throttle: true}, cl.Classify(executionWithStatus(503)))
+	assert.Equal(t, Classification{Fault: true}, cl.Classify(executionWithStatus(502)))
+}
+
+func TestTreatTimeoutAs(t *testing.T) {
+	cl := TreatTimeoutAs(nil, Classification{Error: true})
+	e := executionWithStatus(200)
+	e.Err = timeoutErr
+
+	assert.Equal(t, Classification{Error: true}, cl.Classify(e))
+	assert.Equal(t, Classification{Error: true}, cl.Classify(&request.Execution{Err: timeoutErr}))
+	assert.Equal(t, Classification{Fault: true}, cl.Classify(&request.Execution{Err: errors.New("not a timeout")}))
+	assert.Equal(t, Classification{Fault: true}, cl.Classify(executionWithStatus(500)))
+}
+
+func TestClassifierFunc(t *testing.T) {
+	e := &request.Execution{}
+	var f ClassifierFunc = func(e2 *request.Execution) Classification {
+		assert.Same(t, e, e2)
+		return Classification{Throttle: true}
+	}
+
+	assert.Equal(t, Classification{Throttle: true}, f.Classify(e))
+}
+
+var timeoutErr = &url.Error{Op: "Get", URL: "http://foo.com", Err: context.DeadlineExceeded}
+
+func executionWithStatus(statusCode int) *request.Execution {
+	return &request.Execution{
+		Response: &http.Response{StatusCode: statusCode},
+	}
+}
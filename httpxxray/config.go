@@ -0,0 +1,208 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+// Config holds the settings which customize the X-Ray plugin's
+// behavior. The zero value is a valid Config which produces the same
+// behavior as the basic OnClient and OnHandlers functions.
+//
+// A Config is consumed when the plugin is installed, via either the
+// OnClientWithOptions or the OnHandlersWithOptions function, and
+// changing it afterward has no effect on the installed plugin.
+type Config struct {
+	// Logger is used to log errors encountered by the plugin. If it
+	// implements StructuredLogger, the plugin logs leveled messages
+	// with structured fields. If nil, NopLogger is used.
+	Logger Logger
+
+	// SegmentNamer decides the name of the subsegment representing the
+	// whole request plan execution. If nil, HostNamer is used.
+	SegmentNamer SegmentNamer
+
+	// URLSanitizer removes sensitive data from URLs before they are
+	// recorded in the trace. If nil, DefaultSanitizer is used.
+	URLSanitizer URLSanitizer
+
+	// Propagator writes the trace context of each request attempt into
+	// the outgoing request headers. If nil, XRayPropagator is used.
+	Propagator Propagator
+
+	// Headers lists rules for capturing HTTP request and response
+	// headers onto each attempt subsegment. If empty, no headers are
+	// captured.
+	Headers []HeaderRule
+
+	// Classifier decides the fault, error, and throttle flags of the
+	// attempt and execution subsegments. If nil, DefaultClassifier is
+	// used.
+	Classifier Classifier
+
+	// Facts selects whether facts about the httpx plan execution, such
+	// as the number of attempts, are recorded as metadata, annotations,
+	// or both. If zero, FactsAsMetadata is used.
+	Facts FactTarget
+
+	// Backoff, if true, causes the plugin to record the retry wait
+	// period preceding each new wave of request attempts as a Backoff
+	// subsegment of the execution subsegment.
+	Backoff bool
+
+	// Tracer is the tracing backend which records the plugin's spans.
+	// If nil, the spans are recorded as X-Ray subsegments using the AWS
+	// X-Ray SDK for Go.
+	Tracer Tracer
+
+	// ParentResolver supplies the parent of the execution subsegment
+	// when the request plan's context does not contain one. If nil,
+	// such executions are not traced.
+	ParentResolver ParentResolver
+
+	// Placement decides where the plugin's event handlers are placed
+	// within the handler group when the plugin is first installed on
+	// it. The zero value is Back.
+	Placement Placement
+}
+
+// An Option customizes the Config used to install the X-Ray plugin.
+// Pass options to OnClientWithOptions or OnHandlersWithOptions.
+type Option func(*Config)
+
+// WithConfig returns an Option which replaces the entire Config with
+// c. Options listed after WithConfig are applied on top of c.
+func WithConfig(c Config) Option {
+	return func(dst *Config) {
+		*dst = c
+	}
+}
+
+// WithLogger returns an Option which sets the Logger used by the
+// plugin to log errors it encounters. A nil logger is interpreted as
+// NopLogger.
+func WithLogger(l Logger) Option {
+	return func(c *Config) {
+		c.Logger = l
+	}
+}
+
+// WithSegmentNamer returns an Option which sets the SegmentNamer used
+// to name the subsegment representing the whole request plan
+// execution. A nil namer is interpreted as HostNamer.
+func WithSegmentNamer(n SegmentNamer) Option {
+	return func(c *Config) {
+		c.SegmentNamer = n
+	}
+}
+
+// WithURLSanitizer returns an Option which sets the URLSanitizer used
+// to remove sensitive data from every URL the plugin records. A nil
+// sanitizer is interpreted as DefaultSanitizer. Use ChainSanitizers to
+// combine several sanitizers.
+func WithURLSanitizer(s URLSanitizer) Option {
+	return func(c *Config) {
+		c.URLSanitizer = s
+	}
+}
+
+// WithPropagator returns an Option which sets the Propagator used to
+// send the trace context of each request attempt to the downstream
+// service. A nil propagator is interpreted as XRayPropagator. Use
+// ChainPropagators to send several kinds of trace header, and
+// NewHostPropagator to choose headers by destination host.
+func WithPropagator(p Propagator) Option {
+	return func(c *Config) {
+		c.Propagator = p
+	}
+}
+
+// WithHeaders returns an Option which adds rules for capturing HTTP
+// request and response headers onto each attempt subsegment. The rules
+// are appended to any rules already in the Config.
+func WithHeaders(rules ...HeaderRule) Option {
+	return func(c *Config) {
+		c.Headers = append(c.Headers[:len(c.Headers):len(c.Headers)], rules...)
+	}
+}
+
+// WithClassifier returns an Option which sets the Classifier used to
+// decide the fault, error, and throttle flags of the attempt and
+// execution subsegments. A nil classifier is interpreted as
+// DefaultClassifier.
+func WithClassifier(cl Classifier) Option {
+	return func(c *Config) {
+		c.Classifier = cl
+	}
+}
+
+// WithFacts returns an Option which selects whether facts about the
+// httpx plan execution, such as the number of attempts, are recorded as
+// metadata, annotations, or both. To record both, combine the targets
+// with bitwise OR:
+//
+//	httpxxray.WithFacts(httpxxray.FactsAsMetadata | httpxxray.FactsAsAnnotations)
+func WithFacts(t FactTarget) Option {
+	return func(c *Config) {
+		c.Facts = t
+	}
+}
+
+// WithBackoff returns an Option which causes the plugin to record the
+// retry wait period preceding each new wave of request attempts as a
+// subsegment named "Backoff". The Backoff subsegment is a child of the
+// execution subsegment, spans the wait period, and carries the wait
+// duration in milliseconds as the "backoff_ms" fact.
+//
+// Use this option to see how much of a slow execution was spent
+// waiting between retries rather than communicating with the server.
+func WithBackoff() Option {
+	return func(c *Config) {
+		c.Backoff = true
+	}
+}
+
+// WithTracer returns an Option which sets the tracing backend used to
+// record the plugin's spans. A nil tracer is interpreted as the default
+// backend, which uses the AWS X-Ray SDK for Go.
+func WithTracer(t Tracer) Option {
+	return func(c *Config) {
+		c.Tracer = t
+	}
+}
+
+// WithParentResolver returns an Option which sets the ParentResolver
+// used to supply the parent of the execution subsegment when the request
+// plan's context does not contain an X-Ray segment. Use
+// NewRootSegmentResolver or NewProcessSegmentResolver to trace
+// executions in programs which do not serve traced requests. A nil
+// resolver means such executions are not traced.
+func WithParentResolver(r ParentResolver) Option {
+	return func(c *Config) {
+		c.ParentResolver = r
+	}
+}
+
+// WithPlacement returns an Option which sets where the plugin's event
+// handlers are placed within the handler group, relative to the other
+// handlers in it. See Placement for the guarantees this gives in each
+// event.
+//
+// The placement only takes effect when the plugin is first installed on
+// a handler group. When the plugin is installed again, its handlers keep
+// their places, and the handler of a Before or After placement is not
+// added again.
+func WithPlacement(p Placement) Option {
+	return func(c *Config) {
+		c.Placement = p
+	}
+}
+
+func newConfig(opts []Option) Config {
+	var c Config
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	return c
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("No options", func(t *testing.T) {
+		c := newConfig(nil)
+
+		assert.Equal(t, Config{}, c)
+	})
+	t.Run("nil Option", func(t *testing.T) {
+		c := newConfig([]Option{nil})
+
+		assert.Equal(t, Config{}, c)
+	})
+	t.Run("WithLogger", func(t *testing.T) {
+		l := newMockLogger(t)
+
+		c := newConfig([]Option{WithLogger(l)})
+
+		assert.Same(t, l, c.Logger)
+	})
+	t.Run("WithSegmentNamer", func(t *testing.T) {
+		c := newConfig([]Option{WithSegmentNamer(HostnameNamer)})
+
+		require.NotNil(t, c.SegmentNamer)
+		assert.Equal(t, "foo.com", c.SegmentNamer.Name(newPlan(t, "", "http://foo.com:80")))
+	})
+	t.Run("WithURLSanitizer", func(t *testing.T) {
+		c := newConfig([]Option{WithURLSanitizer(KeepURL)})
+
+		require.NotNil(t, c.URLSanitizer)
+		assert.Equal(t, "http://foo.com?a=b", sanitizeURL(c.URLSanitizer, &url.URL{Scheme: "http", Host: "foo.com", RawQuery: "a=b"}))
+	})
+	t.Run("WithPropagator", func(t *testing.T) {
+		c := newConfig([]Option{WithPropagator(W3CPropagator)})
+
+		assert.NotNil(t, c.Propagator)
+	})
+	t.Run("WithHeaders", func(t *testing.T) {
+		r1 := HeaderRule{Name: "X-Foo"}
+		r2 := HeaderRule{Name: "X-Bar", Source: ResponseHeader}
+
+		c := newConfig([]Option{WithHeaders(r1), WithHeaders(r2)})
+
+		assert.Equal(t, []HeaderRule{r1, r2}, c.Headers)
+	})
+	t.Run("WithClassifier", func(t *testing.T) {
+		c := newConfig([]Option{WithClassifier(TreatAsSuccess(nil, 404))})
+
+		require.NotNil(t, c.Classifier)
+		assert.Equal(t, Classification{}, c.Classifier.Classify(executionWithStatus(404)))
+	})
+	t.Run("WithFacts", func(t *testing.T) {
+		c := newConfig([]Option{WithFacts(FactsAsMetadata | FactsAsAnnotations)})
+
+		assert.Equal(t, FactsAsMetadata|FactsAsAnnotations, c.Facts)
+	})
+	t.Run("WithBackoff", func(t *testing.T) {
+		c := newConfig([]Option{WithBackoff()})
+
+		assert.True(t, c.Backoff)
+	})
+	t.Run("WithTracer", func(t *testing.T) {
+		ft := &fakeTracer{}
+
+		c := newConfig([]Option{WithTracer(ft)})
+
+		assert.Same(t, ft, c.Tracer)
+	})
+	t.Run("WithParentResolver", func(t *testing.T) {
+		r := NewRootSegmentResolver("svc")
+
+		c := newConfig([]Option{WithParentResolver(r)})
+
+		assert.NotNil(t, c.ParentResolver)
+	})
+	t.Run("WithPlacement", func(t *testing.T) {
+		c := newConfig([]Option{WithPlacement(Front)})
+
+		assert.Equal(t, Front, c.Placement)
+	})
+	t.Run("WithConfig", func(t *testing.T) {
+		l1 := newMockLogger(t)
+		l2 := newMockLogger(t)
+
+		c1 := newConfig([]Option{WithLogger(l1), WithConfig(Config{Logger: l2})})
+		c2 := newConfig([]Option{WithConfig(Config{Logger: l2}), WithLogger(l1)})
+
+		assert.Same(t, l2, c1.Logger)
+		assert.Same(t, l1, c2.Logger)
+	})
+}
+
+func TestNewHandler(t *testing.T) {
+	t.Run("nil Logger", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.Equal(t, NopLogger{}, h.logger)
+	})
+	t.Run("nil SegmentNamer", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.NotNil(t, h.namer)
+	})
+	t.Run("nil Classifier", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.NotNil(t, h.classify)
+	})
+	t.Run("nil URLSanitizer", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.NotNil(t, h.sanitizer)
+	})
+	t.Run("nil Propagator", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.NotNil(t, h.propagator)
+	})
+	t.Run("nil Tracer", func(t *testing.T) {
+		h := newHandler(Config{})
+
+		assert.Equal(t, xrayTracer{}, h.tracer)
+	})
+	t.Run("non-nil Tracer", func(t *testing.T) {
+		ft := &fakeTracer{}
+
+		h := newHandler(Config{Tracer: ft})
+
+		assert.Same(t, ft, h.tracer)
+	})
+	t.Run("non-nil Logger", func(t *testing.T) {
+		l := newMockLogger(t)
+
+		h := newHandler(Config{Logger: l})
+
+		assert.Same(t, l, h.logger)
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+)
+
+type withoutTracingKeyType int
+
+var withoutTracingKey = new(withoutTracingKeyType)
+
+type segmentNameKeyType int
+
+var segmentNameKey = new(segmentNameKeyType)
+
+type annotationsKeyType int
+
+var annotationsKey = new(annotationsKeyType)
+
+// WithoutTracing returns a copy of ctx which excludes request plans
+// built with it from tracing. The plugin records no subsegments for
+// the execution of such a plan, sends no trace headers, and logs and
+// counts nothing about it. Use it, for example, for health checks,
+// metrics scrapes, and token refreshes:
+//
+//	pl := request.NewPlanWithContext(httpxxray.WithoutTracing(ctx), "GET", url, nil)
+func WithoutTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutTracingKey, true)
+}
+
+// WithSegmentName returns a copy of ctx which names the execution
+// subsegment of request plans built with it, taking precedence over the
+// configured SegmentNamer. An empty name has no effect. Since X-Ray
+// restricts the characters allowed in a segment name, any character it
+// does not allow is replaced with an underscore.
+func WithSegmentName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, segmentNameKey, segmentName(name))
+}
+
+// WithAnnotations returns a copy of ctx which adds the given
+// annotations to the execution subsegment, and to each attempt
+// subsegment, of request plans built with it. The annotations are
+// merged with any already in ctx, those given replacing any with the
+// same key. The map is copied, so later changes to it have no effect.
+//
+// X-Ray only allows letters, numbers and underscores in annotation
+// keys, so any other character in a key is replaced with an underscore.
+// X-Ray only indexes annotations whose values are strings, numbers or
+// booleans, and ignores annotations with other values.
+func WithAnnotations(ctx context.Context, annotations map[string]interface{}) context.Context {
+	parent := contextAnnotations(ctx)
+	merged := make(map[string]interface{}, len(parent)+len(annotations))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range annotations {
+		merged[annotationKey(k)] = v
+	}
+	return context.WithValue(ctx, annotationsKey, merged)
+}
+
+func tracingDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(withoutTracingKey).(bool)
+	return disabled
+}
+
+func contextSegmentName(ctx context.Context) string {
+	name, _ := ctx.Value(segmentNameKey).(string)
+	return name
+}
+
+func contextAnnotations(ctx context.Context) map[string]interface{} {
+	annotations, _ := ctx.Value(annotationsKey).(map[string]interface{})
+	return annotations
+}
+
+// addContextAnnotations adds the annotations in ctx to s.
+func addContextAnnotations(ctx context.Context, s Span) {
+	for k, v := range contextAnnotations(ctx) {
+		s.AddAnnotation(k, v)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogama/httpx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithoutTracing(t *testing.T) {
+	assert.False(t, tracingDisabled(context.Background()))
+	assert.True(t, tracingDisabled(WithoutTracing(context.Background())))
+}
+
+func TestWithSegmentName(t *testing.T) {
+	assert.Equal(t, "", contextSegmentName(context.Background()))
+	assert.Equal(t, "foo", contextSegmentName(WithSegmentName(context.Background(), "foo")))
+	assert.Equal(t, "billing_v2_ _prod_", contextSegmentName(WithSegmentName(context.Background(), "billing(v2) [prod]")))
+}
+
+func TestWithAnnotations(t *testing.T) {
+	a := map[string]interface{}{"a": 1, "b": "x"}
+	ctx1 := WithAnnotations(context.Background(), a)
+	ctx2 := WithAnnotations(ctx1, map[string]interface{}{"b": "y", "c": true})
+	a["a"] = 2
+
+	assert.Nil(t, contextAnnotations(context.Background()))
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": "x"}, contextAnnotations(ctx1))
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": "y", "c": true}, contextAnnotations(ctx2))
+	ctx3 := WithAnnotations(ctx2, map[string]interface{}{"tenant-id": "acme", "c.d": false})
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": "y", "c": true, "tenant_id": "acme", "c_d": false}, contextAnnotations(ctx3))
+}
+
+func TestPlugin_Context(t *testing.T) {
+	root := &fakeSpan{name: "root"}
+	rootCtx := context.WithValue(context.Background(), fakeSpanKey, root)
+	inst := serverInstruction{StatusCode: 200}
+
+	t.Run("WithoutTracing", func(t *testing.T) {
+		ft := &fakeTracer{}
+		m := newMockLogger(t)
+		cl := &httpx.Client{HTTPDoer: httpServer.Client()}
+		p := InstallOnClient(cl, WithTracer(ft), WithLogger(m))
+
+		e, err := cl.Do(inst.toPlan(WithoutTracing(context.Background()), "", httpServer))
+
+		require.NoError(t, err)
+		m.AssertExpectations(t)
+		assert.Empty(t, ft.spans)
+		assert.Empty(t, e.Request.Header.Get("X-Amzn-Trace-Id"))
+		assert.Equal(t, Stats{NotStarted: map[httpx.Event]uint64{}}, p.Stats())
+	})
+	t.Run("WithSegmentName and WithAnnotations", func(t *testing.T) {
+		ft := &fakeTracer{}
+		cl := &httpx.Client{HTTPDoer: httpServer.Client()}
+		OnClientWithOptions(cl, WithTracer(ft), WithSegmentNamer(NewStaticNamer(map[string]string{"127.0.0.1": "ignored"})))
+		ctx := WithSegmentName(rootCtx, "token-service")
+		ctx = WithAnnotations(ctx, map[string]interface{}{"tenant": "acme"})
+
+		_, err := cl.Do(inst.toPlan(ctx, "", httpServer))
+
+		require.NoError(t, err)
+		exec := ft.find("token-service")
+		require.NotNil(t, exec)
+		assert.Equal(t, "acme", exec.annotations["tenant"])
+		attempt := ft.find("Attempt:0")
+		require.NotNil(t, attempt)
+		assert.Equal(t, "acme", attempt.annotations["tenant"])
+	})
+}
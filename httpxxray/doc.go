@@ -28,5 +28,81 @@ handler.
 
 Use the OnHandlers function to install X-Ray support directly onto an
 httpx.HandlerGroup.
+
+To customize the plugin's behavior, use OnClientWithOptions or
+OnHandlersWithOptions and pass one or more Option values:
+
+	httpxxray.OnClientWithOptions(cl, httpxxray.WithLogger(logger))
+
+Each request attempt sends its trace context to the downstream service
+in the X-Amzn-Trace-Id header. The WithPropagator option changes this,
+for example to also send the W3C traceparent header understood by
+OpenTelemetry, or to choose headers by destination host:
+
+	httpxxray.OnClientWithOptions(cl, httpxxray.WithPropagator(
+		httpxxray.ChainPropagators(
+			httpxxray.XRayPropagator,
+			httpxxray.W3CPropagator,
+		),
+	))
+
+To monitor the plugin itself, install it using Install or
+InstallOnClient, which return the installed Plugin. Its Stats method
+returns health counters, such as the number of subsegments which could
+not be begun, so that tracing breaking silently can be alarmed on:
+
+	p := httpxxray.InstallOnClient(cl)
+	...
+	stats := p.Stats()
+
+The plugin is installed at most once on each handler group, so handler
+groups shared among clients or libraries can be instrumented safely:
+installing again reconfigures the existing plugin with the new options.
+Remove takes the plugin back out of a handler group. A plugin with a
+different tracing backend, such as the OpenTelemetry plugin of package
+httpxotel, is installed independently, so both can trace the same
+requests during a migration.
+
+Individual request plans can be excluded from tracing, or their
+execution subsegment named or annotated, using the plan context:
+
+	untraced := httpxxray.WithoutTracing(ctx)
+	named := httpxxray.WithSegmentName(ctx, "auth")
+	annotated := httpxxray.WithAnnotations(ctx, map[string]interface{}{
+		"tenant": tenantID,
+	})
+
+If the plan context has no parent segment, the execution is not traced
+unless a ParentResolver, set with WithParentResolver, supplies one.
+
+By default the plugin's handlers run after the handlers already in the
+handler group. Use WithPlacement to run the plugin before another
+handler, for example so that a request signing handler signs the trace
+header:
+
+	httpxxray.InstallOnClient(cl, httpxxray.WithPlacement(
+		httpxxray.Before(signer, httpx.BeforeAttempt),
+	))
+
+Requests to AWS service endpoints, whose host names end in
+amazonaws.com, such as API Gateway APIs, S3 pre-signed URLs and other
+SigV4 signed calls, are recorded as AWS calls: the execution subsegment
+is in the "aws" namespace, so it appears as an AWS node on the X-Ray
+service map, and the execution and attempt subsegments record the
+service, region, operation and request ID, where known, in their aws
+block.
+
+When a request attempt or plan execution fails, its subsegment records
+the error as a cause listing the error and each error it wraps, such as
+the *net.OpError and *os.SyscallError within a *url.Error, and has an
+error_kind annotation classifying the failure, for example "dns",
+"tls", "connection_refused" or "attempt_timeout". See ErrorKind for all
+the kinds. Failures can therefore be found with X-Ray filter
+expressions such as annotation.error_kind = "plan_timeout".
+
+By default the plugin records X-Ray subsegments using the AWS X-Ray SDK
+for Go. The WithTracer option substitutes a different tracing backend,
+such as the OpenTelemetry backend provided by package httpxotel, while
+keeping the rest of the plugin's behavior.
 */
 package httpxxray
@@ -0,0 +1,131 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/gogama/httpx/request"
+	"github.com/gogama/httpx/transient"
+)
+
+// An ErrorKind classifies the error which ended a failed request
+// attempt or request plan execution. The plugin records the kind as the
+// "error_kind" annotation on the attempt and execution subsegments, so
+// that traces can be searched by kind of failure, for example using the
+// X-Ray filter expression annotation.error_kind = "dns".
+//
+// The values of the ErrorKind constants are stable, and new kinds may
+// be added in future.
+type ErrorKind string
+
+const (
+	// ErrorKindCancelled indicates the request plan's context was
+	// cancelled.
+	ErrorKindCancelled ErrorKind = "cancelled"
+	// ErrorKindPlanTimeout indicates the request plan's deadline
+	// passed.
+	ErrorKindPlanTimeout ErrorKind = "plan_timeout"
+	// ErrorKindAttemptTimeout indicates a request attempt timed out,
+	// either because of the attempt timeout chosen by the client's
+	// timeout policy, or because of a network timeout.
+	ErrorKindAttemptTimeout ErrorKind = "attempt_timeout"
+	// ErrorKindBodyRead indicates the response body could not be read
+	// in full.
+	ErrorKindBodyRead ErrorKind = "body_read"
+	// ErrorKindDNS indicates the server's host name could not be
+	// resolved.
+	ErrorKindDNS ErrorKind = "dns"
+	// ErrorKindTLS indicates the TLS handshake failed, for example
+	// because the server's certificate was not trusted.
+	ErrorKindTLS ErrorKind = "tls"
+	// ErrorKindConnRefused indicates the server refused the
+	// connection.
+	ErrorKindConnRefused ErrorKind = "connection_refused"
+	// ErrorKindConnReset indicates the connection was reset.
+	ErrorKindConnReset ErrorKind = "connection_reset"
+	// ErrorKindOther indicates an error of any other kind.
+	ErrorKindOther ErrorKind = "other"
+)
+
+// errorKindKey is the annotation key of the error kind.
+const errorKindKey = "error_kind"
+
+// errorKind classifies err, which ended the execution e or its current
+// request attempt. Cancellation and timeouts take precedence, since they
+// explain why a lower-level operation, such as a read, failed. Otherwise,
+// if readingBody is true, the error occurred while reading the response
+// body. The lower-level kinds are found by unwrapping err, which is
+// usually a *url.Error wrapping a *net.OpError.
+func errorKind(e *request.Execution, err error, readingBody bool) ErrorKind {
+	category := transient.Categorize(err)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCancelled
+	case category == transient.Timeout:
+		if e.Plan.Context().Err() == context.DeadlineExceeded {
+			return ErrorKindPlanTimeout
+		}
+		return ErrorKindAttemptTimeout
+	case readingBody:
+		return ErrorKindBodyRead
+	case isDNSError(err):
+		return ErrorKindDNS
+	case isTLSError(err):
+		return ErrorKindTLS
+	case category == transient.ConnRefused:
+		return ErrorKindConnRefused
+	case category == transient.ConnReset:
+		return ErrorKindConnReset
+	default:
+		return ErrorKindOther
+	}
+}
+
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// isTLSError reports whether err is a TLS handshake or certificate
+// verification error. Most TLS errors, including alerts sent by the
+// server, have no dedicated type, but their messages start with "tls: ".
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		invalid          x509.CertificateInvalidError
+		hostname         x509.HostnameError
+		systemRoots      x509.SystemRootsError
+		recordHeader     tls.RecordHeaderError
+	)
+	if errors.As(err, &unknownAuthority) || errors.As(err, &invalid) ||
+		errors.As(err, &hostname) || errors.As(err, &systemRoots) ||
+		errors.As(err, &recordHeader) {
+		return true
+	}
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		if strings.HasPrefix(err.Error(), "tls: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// addErrorKind records the kind of err, if not nil, which ended the
+// execution e or its current request attempt, on s.
+func (h *handler) addErrorKind(s Span, e *request.Execution, err error) {
+	if err == nil {
+		return
+	}
+
+	as, _ := h.getAttemptState(e)
+	s.AddAnnotation(errorKindKey, string(errorKind(e, err, as.readBody != nil)))
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKind(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://foo.com", Err: err}
+	}
+	opErr := func(op string, err error) error {
+		return &net.OpError{Op: op, Net: "tcp", Err: err}
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		err         error
+		readingBody bool
+		kind        ErrorKind
+	}{
+		{
+			name: "Cancelled",
+			err:  urlErr(context.Canceled),
+			kind: ErrorKindCancelled,
+		},
+		{
+			name:        "Cancelled[Reading body]",
+			err:         urlErr(context.Canceled),
+			readingBody: true,
+			kind:        ErrorKindCancelled,
+		},
+		{
+			name: "Attempt timeout",
+			err:  urlErr(context.DeadlineExceeded),
+			kind: ErrorKindAttemptTimeout,
+		},
+		{
+			name: "Attempt timeout[Network]",
+			err:  urlErr(opErr("dial", os.NewSyscallError("connect", syscall.ETIMEDOUT))),
+			kind: ErrorKindAttemptTimeout,
+		},
+		{
+			name: "Plan timeout",
+			ctx:  expired,
+			err:  urlErr(context.DeadlineExceeded),
+			kind: ErrorKindPlanTimeout,
+		},
+		{
+			name:        "Body read",
+			err:         io.ErrUnexpectedEOF,
+			readingBody: true,
+			kind:        ErrorKindBodyRead,
+		},
+		{
+			name:        "Body read[Connection reset]",
+			err:         opErr("read", os.NewSyscallError("read", syscall.ECONNRESET)),
+			readingBody: true,
+			kind:        ErrorKindBodyRead,
+		},
+		{
+			name: "DNS",
+			err:  urlErr(opErr("dial", &net.DNSError{Err: "no such host", Name: "foo.com", IsNotFound: true})),
+			kind: ErrorKindDNS,
+		},
+		{
+			name: "TLS[Unknown authority]",
+			err:  urlErr(x509.UnknownAuthorityError{}),
+			kind: ErrorKindTLS,
+		},
+		{
+			name: "TLS[Hostname]",
+			err:  urlErr(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "foo.com"}),
+			kind: ErrorKindTLS,
+		},
+		{
+			name: "TLS[Record header]",
+			err:  urlErr(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}),
+			kind: ErrorKindTLS,
+		},
+		{
+			name: "TLS[Alert]",
+			err:  urlErr(opErr("remote error", errors.New("tls: bad certificate"))),
+			kind: ErrorKindTLS,
+		},
+		{
+			name: "Connection refused",
+			err:  urlErr(opErr("dial", os.NewSyscallError("connect", syscall.ECONNREFUSED))),
+			kind: ErrorKindConnRefused,
+		},
+		{
+			name: "Connection reset",
+			err:  urlErr(opErr("read", os.NewSyscallError("read", syscall.ECONNRESET))),
+			kind: ErrorKindConnReset,
+		},
+		{
+			name: "Other",
+			err:  urlErr(fmt.Errorf("something else")),
+			kind: ErrorKindOther,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := testCase.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			e := newExecutionWithContext(t, ctx)
+
+			assert.Equal(t, testCase.kind, errorKind(e, testCase.err, testCase.readingBody))
+		})
+	}
+}
+
+func TestAddErrorKind(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "http://foo.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	truncated := io.ErrUnexpectedEOF
+
+	root := &fakeSpan{name: "root"}
+	p, err := request.NewPlanWithContext(context.WithValue(context.Background(), fakeSpanKey, root), "GET", "http://foo.com", nil)
+	require.NoError(t, err)
+	e := &request.Execution{Plan: p}
+	ft := &fakeTracer{}
+	h := newHandler(Config{})
+	h.tracer = ft
+
+	h.Handle(httpx.BeforeExecutionStart, e)
+	// Attempt 0: the connection is refused.
+	e.Request = e.Plan.ToRequest(e.Plan.Context())
+	h.Handle(httpx.BeforeAttempt, e)
+	e.Err = refused
+	h.Handle(httpx.AfterAttempt, e)
+	// Attempt 1: reading the response body fails.
+	e.Attempt, e.Err = 1, nil
+	e.Request = e.Plan.ToRequest(e.Plan.Context())
+	h.Handle(httpx.BeforeAttempt, e)
+	e.Response = &http.Response{StatusCode: 200}
+	h.Handle(httpx.BeforeReadBody, e)
+	e.Err = truncated
+	h.Handle(httpx.AfterAttempt, e)
+	h.Handle(httpx.AfterExecutionEnd, e)
+
+	for name, kind := range map[string]ErrorKind{
+		"foo.com":   ErrorKindBodyRead,
+		"Attempt:0": ErrorKindConnRefused,
+		"Attempt:1": ErrorKindBodyRead,
+	} {
+		s := ft.find(name)
+		require.NotNil(t, s, name)
+		assert.Equal(t, string(kind), s.annotations[errorKindKey], name)
+	}
+	s := ft.find("ReadBody")
+	require.NotNil(t, s)
+	assert.NotContains(t, s.annotations, errorKindKey)
+}
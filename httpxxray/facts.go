@@ -0,0 +1,49 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+// FactTarget selects where the plugin records facts about the httpx
+// plan execution, such as the number of attempts and waves, the
+// response body length, and whether the plan timed out.
+//
+// FactTarget values are bit flags and may be combined using bitwise OR.
+// The zero value is interpreted as FactsAsMetadata.
+//
+// The execution subsegment records the facts "attempts", "waves",
+// "body_length" and, if the plan timed out, "plan_timeout". Each
+// attempt subsegment records "attempt", "wave", "outcome" and
+// "body_length". An attempt which timed out additionally records
+// "attempt_timeout" and, if the attempt had a deadline, "timeout_ms".
+// The timeout_ms fact is the attempt timeout rounded to the nearest
+// millisecond. Since httpx bounds each attempt by the plan deadline,
+// it is the lesser of the timeout chosen by the client's timeout
+// policy and the time remaining until the plan deadline when the
+// attempt started.
+type FactTarget int
+
+const (
+	// FactsAsMetadata records each fact as X-Ray metadata in the
+	// "httpx" namespace, for example "attempts" or "plan_timeout".
+	// Metadata is not indexed, so it cannot be used in X-Ray filter
+	// expressions.
+	FactsAsMetadata FactTarget = 1 << iota
+
+	// FactsAsAnnotations records each fact as an X-Ray annotation
+	// whose key has the "httpx_" prefix, for example "httpx_attempts"
+	// or "httpx_plan_timeout". Annotations are indexed, so they can be
+	// used in X-Ray filter expressions such as:
+	//
+	//	annotation.httpx_attempts > 2
+	FactsAsAnnotations
+)
+
+func addFact(s Span, t FactTarget, key string, value interface{}) {
+	if t == 0 || t&FactsAsMetadata != 0 {
+		s.AddMetadata("httpx", key, value)
+	}
+	if t&FactsAsAnnotations != 0 {
+		s.AddAnnotation("httpx_"+key, value)
+	}
+}
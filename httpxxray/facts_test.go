@@ -0,0 +1,44 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddFact(t *testing.T) {
+	testCases := []struct {
+		name        string
+		target      FactTarget
+		metadata    bool
+		annotations bool
+	}{
+		{"zero", 0, true, false},
+		{"FactsAsMetadata", FactsAsMetadata, true, false},
+		{"FactsAsAnnotations", FactsAsAnnotations, false, true},
+		{"both", FactsAsMetadata | FactsAsAnnotations, true, true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, seg := newNonDummySegment(t)
+			defer seg.Close(nil)
+
+			addFact(xraySpan{seg}, testCase.target, "attempts", 3)
+
+			if testCase.metadata {
+				assert.Equal(t, map[string]interface{}{"attempts": 3}, seg.Metadata["httpx"])
+			} else {
+				assert.NotContains(t, seg.Metadata, "httpx")
+			}
+			if testCase.annotations {
+				assert.Equal(t, map[string]interface{}{"httpx_attempts": 3}, seg.Annotations)
+			} else {
+				assert.Empty(t, seg.Annotations)
+			}
+		})
+	}
+}
@@ -6,121 +6,344 @@ package httpxxray
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptrace"
-	"net/url"
 	"strconv"
+	"sync/atomic"
+	"time"
 
-	"github.com/aws/aws-xray-sdk-go/v2/xray"
 	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/racing"
 	"github.com/gogama/httpx/request"
 )
 
 type handler struct {
-	logger Logger
+	tracer     Tracer
+	logger     Logger
+	namer      SegmentNamer
+	sanitizer  URLSanitizer
+	propagator Propagator
+	headers    []HeaderRule
+	classify   Classifier
+	facts      FactTarget
+	backoff    bool
+	resolver   ParentResolver
+	stats      *counters
+}
+
+func newHandler(c Config) *handler {
+	h := &handler{
+		tracer:     c.Tracer,
+		logger:     c.Logger,
+		namer:      c.SegmentNamer,
+		sanitizer:  c.URLSanitizer,
+		propagator: c.Propagator,
+		headers:    append([]HeaderRule(nil), c.Headers...),
+		classify:   c.Classifier,
+		facts:      c.Facts,
+		backoff:    c.Backoff,
+		resolver:   c.ParentResolver,
+		stats:      &counters{},
+	}
+	if h.tracer == nil {
+		h.tracer = xrayTracer{}
+	}
+	if h.logger == nil {
+		h.logger = NopLogger{}
+	}
+	if h.namer == nil {
+		h.namer = HostNamer
+	}
+	if h.sanitizer == nil {
+		h.sanitizer = DefaultSanitizer
+	}
+	if h.propagator == nil {
+		h.propagator = XRayPropagator
+	}
+	if h.classify == nil {
+		h.classify = DefaultClassifier
+	}
+	return h
 }
 
 func (h *handler) Handle(evt httpx.Event, e *request.Execution) {
 	switch evt {
 	case httpx.BeforeExecutionStart:
-		beforeExecutionStart(h.logger, e)
+		h.beforeExecutionStart(e)
 	case httpx.BeforeAttempt:
-		beforeAttempt(h.logger, e)
+		h.beforeAttempt(e)
+	case httpx.BeforeReadBody:
+		h.beforeReadBody(e)
+	case httpx.AfterAttemptTimeout:
+		h.afterAttemptTimeout(e)
 	case httpx.AfterAttempt:
-		afterAttempt(e)
+		h.afterAttempt(e)
 	case httpx.AfterPlanTimeout:
-		afterPlanTimeout(e)
+		h.afterPlanTimeout(e)
 	case httpx.AfterExecutionEnd:
-		afterExecutionEnd(e)
+		h.afterExecutionEnd(e)
 	default:
 		panic("httpxxray: unsupported event")
 	}
 }
 
-func beforeExecutionStart(l Logger, e *request.Execution) {
-	ctx, seg := xray.BeginSubsegment(e.Plan.Context(), host(e.Plan))
-	if seg == nil {
-		logSubsegmentNotStarted(httpx.BeforeExecutionStart, l, e.Plan)
+func (h *handler) beforeExecutionStart(e *request.Execution) {
+	name := h.segmentName(e.Plan)
+	ctx, s := h.tracer.Begin(e.Plan.Context(), ExecutionSpan, name, time.Time{})
+	if s == nil && h.resolver != nil {
+		ctx, s = h.beginResolved(e, name)
+	}
+	if s == nil {
+		h.notStarted(httpx.BeforeExecutionStart, e)
 		return
 	}
 
-	seg.Lock()
-	defer seg.Unlock()
-	seg.Namespace = "remote"
-
+	atomic.AddUint64(&h.stats.executionsTraced, 1)
+	addContextAnnotations(ctx, s)
 	e.Plan = e.Plan.WithContext(ctx)
 }
 
-func afterExecutionEnd(e *request.Execution) {
-	seg := xray.GetSegment(e.Plan.Context())
-	if seg == nil {
+// beginResolved begins the execution span as a child of the parent
+// supplied by the ParentResolver, remembering the resolver's release
+// function, if any, until the execution ends.
+func (h *handler) beginResolved(e *request.Execution, name string) (context.Context, Span) {
+	parent, release := h.resolver.Resolve(e.Plan.Context(), e.Plan)
+	if parent == nil {
+		return nil, nil
+	}
+
+	ctx, s := h.tracer.Begin(parent, ExecutionSpan, name, time.Time{})
+	if s == nil {
+		if release != nil {
+			release()
+		}
+		return nil, nil
+	}
+
+	h.putExecutionState(e).release = release
+	return ctx, s
+}
+
+func (h *handler) afterExecutionEnd(e *request.Execution) {
+	// The parent supplied by the ParentResolver is released only after
+	// the execution span is closed.
+	if es, _ := e.Value(h).(*executionState); es != nil && es.release != nil {
+		defer es.release()
+	}
+
+	s := h.tracer.Get(e.Plan.Context())
+	if s == nil {
+		atomic.AddUint64(&h.stats.missingOnClose, 1)
 		return
 	}
-	defer seg.Close(e.Err)
-
-	setSegmentHTTPResponse(seg, e.Response)
-	setSegmentBodyLen(seg, e.Body)
-	setSegmentExecutionMetadata(seg, e.Attempt+1, e.Wave+1)
-
-	// AWS X-Ray for Go has bugs both in the Lambda and non-Lambda case that
-	// result the execution sub-segment not being emitted in some edge cases
-	// because various race conditions cause dangling "open" sub-subsegments
-	// under the X-Ray HTTP `connect` subsegment (which in our scheme is itself
-	// a sub-subsegment of the attempt subsegment).
-	//
-	// The most general and effective way I have found to force X-Ray to emit
-	// the execution subsegment even when these issues arise is to set its
-	// "context done" flag, which allows the subsegment to be emitted even when
-	// it has dangling open sub-subsegments.
-	seg.ContextDone = true
-}
-
-func beforeAttempt(l Logger, e *request.Execution) {
-	ctx, seg := xray.BeginSubsegment(e.Request.Context(), fmt.Sprintf("Attempt:%d", e.Attempt))
-	if seg == nil {
-		logSubsegmentNotStarted(httpx.BeforeAttempt, l, e.Plan)
+	defer h.close(s, e.Err, h.classify.Classify(e))
+
+	setSegmentHTTPResponse(s, e.Response)
+	setSegmentBodyLen(s, h.facts, e.Body)
+	setSegmentExecutionMetadata(s, h.facts, e.Attempt+1, e.Wave+1)
+	setSegmentAWS(s, e.Request, e.Response)
+	h.addErrorKind(s, e, e.Err)
+}
+
+func (h *handler) beforeAttempt(e *request.Execution) {
+	if h.backoff {
+		h.recordBackoff(e)
+	}
+	h.putExecutionState(e).countAttempt(e.Wave)
+
+	ctx, s := h.tracer.Begin(e.Request.Context(), AttemptSpan, fmt.Sprintf("Attempt:%d", e.Attempt), time.Time{})
+	if s == nil {
+		h.notStarted(httpx.BeforeAttempt, e)
 		return
 	}
 
-	setSegmentAttemptMetadata(seg, e.Attempt)
+	atomic.AddUint64(&h.stats.attemptsTraced, 1)
+	addContextAnnotations(ctx, s)
+	setSegmentAttemptMetadata(s, h.facts, e.Attempt, e.Wave)
+	captureHeaders(s, h.headers, RequestHeader, e.Request.Header)
 
-	httpSubsegments, trace := newClientTrace(ctx)
-	ctx = httptrace.WithClientTrace(ctx, trace)
+	trace := h.tracer.TraceHTTP(ctx)
+	ctx = httptrace.WithClientTrace(ctx, trace.ClientTrace())
 	req := e.Request.WithContext(ctx)
 
-	seg.Lock()
-	defer seg.Unlock()
-	reqData := seg.GetHTTP().GetRequest()
-	reqData.Method = req.Method
-	reqData.URL = stripQuery(*req.URL)
-	req.Header.Set(xray.TraceIDHeaderKey, seg.DownstreamHeader().String())
+	s.SetHTTPRequest(req.Method, sanitizeURL(h.sanitizer, req.URL))
+	h.propagator.Inject(req, s.TraceContext())
 
-	putAttemptState(e, attemptState{httpSubsegments: httpSubsegments})
+	h.putAttemptState(e, attemptState{
+		trace:   trace,
+		timeout: attemptTimeout(ctx),
+	})
 	e.Request = req
 }
 
-func afterAttempt(e *request.Execution) {
-	ctx := e.Request.Context()
-	seg := xray.GetSegment(ctx)
-	if seg == nil {
+// recordBackoff records the time spent waiting between the end of the
+// previous wave and the start of the first attempt in a new wave, i.e.
+// the retry wait period, as a Backoff subsegment whose start and end
+// times match the wait period.
+//
+// Within a wave, additional racing attempts are started according to
+// the racing policy's schedule rather than after a retry wait, so only
+// the first attempt of each wave after the first has a backoff.
+func (h *handler) recordBackoff(e *request.Execution) {
+	es, _ := e.Value(h).(*executionState)
+	if es == nil || es.lastAttemptEnd.IsZero() || e.Wave <= es.lastWave {
 		return
 	}
 
-	defer seg.Close(e.Err)
+	ctx := e.Plan.Context()
+	if h.tracer.Get(ctx) == nil {
+		return
+	}
 
-	setSegmentHTTPResponse(seg, e.Response)
-	setSegmentBodyLen(seg, e.Body)
+	start := es.lastAttemptEnd
+	_, s := h.tracer.Begin(ctx, BackoffSpan, "Backoff", start)
+	if s == nil {
+		atomic.AddUint64(&h.stats.notStarted[httpx.BeforeAttempt], 1)
+		return
+	}
+
+	wait := time.Since(start)
+	es.lastAttemptEnd = time.Time{}
+	addFact(s, h.facts, "backoff_ms", wait.Milliseconds())
+	s.Close(nil, Classification{})
 }
 
-func afterPlanTimeout(e *request.Execution) {
-	ctx := e.Plan.Context()
-	seg := xray.GetSegment(ctx)
-	if seg == nil {
+func (h *handler) beforeReadBody(e *request.Execution) {
+	_, s := h.tracer.Begin(e.Request.Context(), ReadBodySpan, "ReadBody", time.Time{})
+	if s == nil {
+		h.notStarted(httpx.BeforeReadBody, e)
+		return
+	}
+
+	as, _ := h.getAttemptState(e)
+	as.readBody = s
+	h.putAttemptState(e, as)
+}
+
+func (h *handler) afterAttemptTimeout(e *request.Execution) {
+	s := h.tracer.Get(e.Request.Context())
+	if s == nil {
+		return
+	}
+
+	addFact(s, h.facts, "attempt_timeout", true)
+	if as, err := h.getAttemptState(e); err == nil && as.timeout > 0 {
+		addFact(s, h.facts, "timeout_ms", as.timeout.Round(time.Millisecond).Milliseconds())
+	}
+}
+
+func (h *handler) afterAttempt(e *request.Execution) {
+	s := h.tracer.Get(e.Request.Context())
+	if s == nil {
+		atomic.AddUint64(&h.stats.missingOnClose, 1)
+		return
+	}
+
+	// When racing is enabled, attempts which lose the race are cancelled
+	// as redundant. Cancellation is the racing feature working as
+	// intended, so a redundant attempt is neither a fault nor an error.
+	es := h.putExecutionState(e)
+	err, cls, outcome := e.Err, h.classify.Classify(e), outcomeSucceeded
+	h.closeReadBody(e, cls)
+	if errors.Is(err, racing.Redundant) {
+		err, cls, outcome = nil, Classification{}, outcomeCancelledByRace
+	} else if err != nil || cls.Fault || cls.Error {
+		outcome = outcomeFailed
+	} else if es.raced(e.Wave) {
+		outcome = outcomeWinner
+	}
+	defer h.close(s, err, cls)
+
+	// The httptrace hooks may still be running, or may never report the
+	// end of an operation interrupted by the attempt ending, so any
+	// connection-level subsegments left open are ended here, before the
+	// attempt subsegment is closed.
+	if as, asErr := h.getAttemptState(e); asErr == nil {
+		if closer, ok := as.trace.(ConnTraceCloser); ok {
+			closer.Close(err)
+		}
+	}
+
+	es.lastAttemptEnd = time.Now()
+	es.lastWave = e.Wave
+
+	addFact(s, h.facts, "outcome", outcome)
+	setSegmentHTTPResponse(s, e.Response)
+	setSegmentAWS(s, e.Request, e.Response)
+	setSegmentBodyLen(s, h.facts, e.Body)
+	captureHeaders(s, h.headers, ResponseHeader, e.Header())
+	h.addErrorKind(s, e, err)
+}
+
+// closeReadBody closes the ReadBody subsegment of the current attempt,
+// if there is one. The classification cls is the attempt's
+// classification.
+//
+// If the read failed, the ReadBody subsegment takes its flags from cls,
+// so the configured classifier decides them. A complete read has no
+// flags, however, because the response status code, which usually
+// drives the attempt's flags, says nothing about the read itself. A
+// read cut short because the attempt lost a race is likewise not a
+// failure, and is marked with the read_cancelled fact.
+func (h *handler) closeReadBody(e *request.Execution, cls Classification) {
+	as, asErr := h.getAttemptState(e)
+	if asErr != nil || as.readBody == nil {
+		return
+	}
+
+	// The body is read with ioutil.ReadAll, so even if the read was cut
+	// short by an error, the body contains the bytes read before the
+	// error occurred.
+	addFact(as.readBody, h.facts, "bytes_read", len(e.Body))
+	err := e.Err
+	switch {
+	case err == nil:
+		cls = Classification{}
+	case errors.Is(err, racing.Redundant):
+		addFact(as.readBody, h.facts, "read_cancelled", true)
+		err, cls = nil, Classification{}
+	case e.Timeout():
+		addFact(as.readBody, h.facts, "read_timeout", true)
+	default:
+		addFact(as.readBody, h.facts, "read_error", true)
+	}
+	h.close(as.readBody, err, cls)
+}
+
+// close closes s, counting it if it is closed with an error.
+func (h *handler) close(s Span, err error, cls Classification) {
+	if err != nil {
+		atomic.AddUint64(&h.stats.closedWithError, 1)
+	}
+	s.Close(err, cls)
+}
+
+// notStarted counts and logs a subsegment which could not be begun in
+// event evt.
+func (h *handler) notStarted(evt httpx.Event, e *request.Execution) {
+	atomic.AddUint64(&h.stats.notStarted[evt], 1)
+	logSubsegmentNotStarted(evt, h.logger, e)
+}
+
+func (h *handler) afterPlanTimeout(e *request.Execution) {
+	s := h.tracer.Get(e.Plan.Context())
+	if s == nil {
 		return
 	}
-	_ = seg.AddMetadataToNamespace("httpx", "plan_timeout", true)
+	addFact(s, h.facts, "plan_timeout", true)
+}
+
+func (h *handler) segmentName(p *request.Plan) string {
+	if name := contextSegmentName(p.Context()); name != "" {
+		return name
+	}
+	if name := h.namer.Name(p); name != "" {
+		return name
+	}
+
+	return host(p)
 }
 
 func host(p *request.Plan) string {
@@ -131,108 +354,119 @@ func host(p *request.Plan) string {
 	return p.URL.Host
 }
 
-func newClientTrace(ctx context.Context) (*xray.HTTPSubsegments, *httptrace.ClientTrace) {
-	httpSubsegments := xray.NewHTTPSubsegments(ctx)
-	return httpSubsegments, &httptrace.ClientTrace{
-		GetConn: func(hostPort string) {
-			httpSubsegments.GetConn(hostPort)
-		},
-		DNSStart: func(info httptrace.DNSStartInfo) {
-			httpSubsegments.DNSStart(info)
-		},
-		DNSDone: func(info httptrace.DNSDoneInfo) {
-			httpSubsegments.DNSDone(info)
-		},
-		ConnectStart: func(network, addr string) {
-			httpSubsegments.ConnectStart(network, addr)
-		},
-		ConnectDone: func(network, addr string, err error) {
-			httpSubsegments.ConnectDone(network, addr, err)
-		},
-		TLSHandshakeStart: func() {
-			httpSubsegments.TLSHandshakeStart()
-		},
-		TLSHandshakeDone: func(connState tls.ConnectionState, err error) {
-			httpSubsegments.TLSHandshakeDone(connState, err)
-		},
-		GotConn: func(info httptrace.GotConnInfo) {
-			httpSubsegments.GotConn(&info, nil)
-		},
-		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			httpSubsegments.WroteRequest(info)
-		},
-		GotFirstResponseByte: func() {
-			httpSubsegments.GotFirstResponseByte()
-		},
-	}
-}
-
-func stripQuery(u url.URL) string {
-	u.RawQuery = ""
-	return u.String()
-}
-
-func setSegmentHTTPResponse(seg *xray.Segment, resp *http.Response) {
+func setSegmentHTTPResponse(s Span, resp *http.Response) {
 	if resp == nil {
 		return
 	}
 
-	seg.Lock()
-	defer seg.Unlock()
+	contentLength, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
+	s.SetHTTPResponse(resp.StatusCode, contentLength)
+}
 
-	// Emulate HTTP header handling logic within Capture closure in X-Ray
-	// SDK: xray/client.go.
-	respData := seg.GetHTTP().GetResponse()
-	respData.Status = resp.StatusCode
-	respData.ContentLength, _ = strconv.Atoi(resp.Header.Get("Content-Length"))
-	switch resp.StatusCode / 100 {
-	case 4:
-		seg.Error = true
-		if resp.StatusCode == 429 {
-			seg.Throttle = true
-		}
-	case 5:
-		seg.Fault = true
+// setSegmentAWS records the AWS service, region, operation and request
+// ID if req was sent to an AWS service endpoint and s is an AWSSpan.
+func setSegmentAWS(s Span, req *http.Request, resp *http.Response) {
+	aws, ok := s.(AWSSpan)
+	if !ok || req == nil {
+		return
+	}
+
+	if call, ok := awsCall(req, resp); ok {
+		aws.SetAWS(call)
 	}
 }
 
-func setSegmentBodyLen(seg *xray.Segment, body []byte) {
+func setSegmentBodyLen(s Span, t FactTarget, body []byte) {
 	// Add body length if available. A nil body means the request attempt
 	// errored out before the response body could be read, whereas a non-
 	// nil zero-length body means the response body was successfully read
 	// but empty.
 	if body != nil {
-		_ = seg.AddMetadataToNamespace("httpx", "body_length", len(body))
+		addFact(s, t, "body_length", len(body))
 	}
 }
 
-func setSegmentExecutionMetadata(seg *xray.Segment, attempts int, waves int) {
-	_ = seg.AddMetadataToNamespace("httpx", "attempts", attempts)
-	_ = seg.AddMetadataToNamespace("httpx", "waves", waves)
+func setSegmentExecutionMetadata(s Span, t FactTarget, attempts int, waves int) {
+	addFact(s, t, "attempts", attempts)
+	addFact(s, t, "waves", waves)
 }
 
-func setSegmentAttemptMetadata(seg *xray.Segment, attempt int) {
-	_ = seg.AddMetadataToNamespace("httpx", "attempt", attempt)
+func setSegmentAttemptMetadata(s Span, t FactTarget, attempt int, wave int) {
+	addFact(s, t, "attempt", attempt)
+	addFact(s, t, "wave", wave)
 }
 
-type executionStateKeyType int
-
-var executionStateKey = new(executionStateKeyType)
+// Attempt outcomes, recorded as the "outcome" fact on each attempt
+// subsegment.
+const (
+	// outcomeSucceeded indicates the attempt ended without error, its
+	// response was not classified as a fault or error, and it was the
+	// only attempt started in its wave.
+	outcomeSucceeded = "succeeded"
+	// outcomeWinner indicates the attempt ended without error, its
+	// response was not classified as a fault or error, and it won a
+	// race against at least one other attempt in the same wave.
+	outcomeWinner = "winner"
+	// outcomeCancelledByRace indicates the attempt was cancelled
+	// because another racing attempt in the same wave ended first.
+	outcomeCancelledByRace = "cancelled_by_race"
+	// outcomeFailed indicates the attempt ended in error, or its
+	// response was classified as a fault or error.
+	outcomeFailed = "failed"
+)
 
+// An executionState is the state of a request plan execution traced by
+// a handler. It is stored in the execution under the handler itself as
+// the key, so that plugins installed on the same handler group with
+// different tracing backends keep separate states.
 type executionState struct {
 	as []attemptState
+
+	// release, if not nil, releases the parent of the execution span
+	// supplied by the ParentResolver.
+	release func()
+
+	// lastAttemptEnd is the time at which the most recent request
+	// attempt ended, and lastWave is the wave it belonged to.
+	lastAttemptEnd time.Time
+	lastWave       int
+
+	// waveAttempts is the number of request attempts started so far in
+	// wave attemptWave, which is the most recent wave.
+	attemptWave  int
+	waveAttempts int
+}
+
+func (es *executionState) countAttempt(wave int) {
+	if wave != es.attemptWave {
+		es.attemptWave, es.waveAttempts = wave, 0
+	}
+	es.waveAttempts++
+}
+
+// raced reports whether more than one request attempt was started in
+// the given wave, i.e. whether the wave's attempts actually raced.
+func (es *executionState) raced(wave int) bool {
+	return wave == es.attemptWave && es.waveAttempts > 1
 }
 
 type attemptState struct {
-	httpSubsegments *xray.HTTPSubsegments
+	trace    ConnTrace
+	timeout  time.Duration
+	readBody Span
 }
 
-func putAttemptState(e *request.Execution, as attemptState) {
-	es, _ := e.Value(executionStateKey).(*executionState)
+func (h *handler) putExecutionState(e *request.Execution) *executionState {
+	es, _ := e.Value(h).(*executionState)
 	if es == nil {
 		es = &executionState{}
-		e.SetValue(executionStateKey, es)
+		e.SetValue(h, es)
 	}
+	return es
+}
+
+func (h *handler) putAttemptState(e *request.Execution, as attemptState) {
+	es := h.putExecutionState(e)
 	if len(es.as) == e.Attempt {
 		es.as = append(es.as, attemptState{})
 	} else if len(es.as) < e.Attempt {
@@ -243,8 +477,52 @@ func putAttemptState(e *request.Execution, as attemptState) {
 	es.as[e.Attempt] = as
 }
 
-const subsegmentNotStartedF = "httpxxray: [WARN] Unable to begin X-Ray subsegment in event %s (%s)"
+func (h *handler) getAttemptState(e *request.Execution) (attemptState, error) {
+	es, _ := e.Value(h).(*executionState)
+	if es == nil {
+		return attemptState{}, errors.New("httpxxray: no execution state")
+	}
+	if len(es.as) <= e.Attempt {
+		return attemptState{}, fmt.Errorf("httpxxray: no attempt state %d", e.Attempt)
+	}
+	return es.as[e.Attempt], nil
+}
+
+// attemptTimeout returns the timeout in force for a request attempt,
+// as given by the deadline on the attempt's context. Since httpx
+// derives each attempt context from the plan context, this is the
+// lesser of the timeout chosen by the client's timeout policy and the
+// time remaining until the plan deadline, if any.
+//
+// Because httpx creates the attempt context shortly before firing the
+// BeforeAttempt event, the result is slightly less than the configured
+// timeout, so callers should round it before recording it.
+func attemptTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+
+	return time.Until(deadline)
+}
+
+const (
+	subsegmentNotStartedF   = "httpxxray: [WARN] Unable to begin X-Ray subsegment in event %s (%s)"
+	subsegmentNotStartedMsg = "unable to begin X-Ray subsegment"
+)
+
+func logSubsegmentNotStarted(evt httpx.Event, l Logger, e *request.Execution) {
+	sl, ok := l.(StructuredLogger)
+	if !ok {
+		l.Printf(subsegmentNotStartedF, evt.Name(), host(e.Plan))
+		return
+	}
 
-func logSubsegmentNotStarted(evt httpx.Event, l Logger, p *request.Plan) {
-	l.Printf(subsegmentNotStartedF, evt.Name(), host(p))
+	ctx := e.Plan.Context()
+	fields := []Field{{"event", evt.Name()}, {"host", host(e.Plan)}}
+	if evt != httpx.BeforeExecutionStart {
+		ctx = e.Request.Context()
+		fields = append(fields, Field{"attempt", e.Attempt})
+	}
+	sl.Log(ctx, LevelWarn, subsegmentNotStartedMsg, fields...)
 }
@@ -7,11 +7,11 @@ package httpxxray
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-xray-sdk-go/v2/xray"
 
@@ -28,65 +28,119 @@ import (
 func TestHandler_Handle(t *testing.T) {
 	t.Run("unsupported event", func(t *testing.T) {
 		assert.PanicsWithValue(t, "httpxxray: unsupported event", func() {
-			h := &handler{&NopLogger{}}
-			h.Handle(httpx.BeforeReadBody, nil)
+			h := newHandler(Config{Logger: &NopLogger{}})
+			h.Handle(httpx.Event(-1), nil)
 		})
 	})
 	t.Run("BeforeExecutionStart[No parent segment]", func(t *testing.T) {
 		e := newExecutionWithContext(t, context.TODO())
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 		m.On("Printf", subsegmentNotStartedF, []interface{}{"BeforeExecutionStart", "foo.com"}).Once()
 
 		h.Handle(httpx.BeforeExecutionStart, e)
 
 		m.AssertExpectations(t)
+		assert.Equal(t, map[httpx.Event]uint64{httpx.BeforeExecutionStart: 1}, h.stats.snapshot().NotStarted)
 	})
 	t.Run("BeforeAttempt[No execution segment]", func(t *testing.T) {
 		e := newExecutionWithContext(t, context.TODO())
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 		m.On("Printf", subsegmentNotStartedF, []interface{}{"BeforeAttempt", "foo.com"}).Once()
 
 		e.Request = e.Plan.ToRequest(context.TODO())
 		h.Handle(httpx.BeforeAttempt, e)
 
+		m.AssertExpectations(t)
+		assert.Equal(t, map[httpx.Event]uint64{httpx.BeforeAttempt: 1}, h.stats.snapshot().NotStarted)
+	})
+	t.Run("BeforeAttempt[No execution segment, StructuredLogger]", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.TODO())
+		e.Attempt = 2
+		m := newMockStructuredLogger(t)
+		h := newHandler(Config{Logger: m})
+		m.On("Log", LevelWarn, subsegmentNotStartedMsg, []Field{
+			{"event", "BeforeAttempt"},
+			{"host", "foo.com"},
+			{"attempt", 2},
+		}).Once()
+
+		e.Request = e.Plan.ToRequest(context.TODO())
+		h.Handle(httpx.BeforeAttempt, e)
+
 		m.AssertExpectations(t)
 	})
 	t.Run("AfterAttempt[No attempt segment]", func(t *testing.T) {
 		e := newExecutionWithContext(t, context.TODO())
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 
 		e.Request = e.Plan.ToRequest(context.TODO())
 		h.Handle(httpx.AfterAttempt, e)
 
 		m.AssertExpectations(t)
+		assert.Equal(t, uint64(1), h.stats.snapshot().MissingOnClose)
 	})
 	t.Run("AfterPlanTimeout[No execution segment]", func(t *testing.T) {
 		e := newExecutionWithContext(t, context.TODO())
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 
 		h.Handle(httpx.AfterPlanTimeout, e)
 
 		m.AssertExpectations(t)
 	})
+	t.Run("BeforeExecutionStart[No parent segment, StructuredLogger]", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.TODO())
+		m := newMockStructuredLogger(t)
+		h := newHandler(Config{Logger: m})
+		m.On("Log", LevelWarn, subsegmentNotStartedMsg, []Field{
+			{"event", "BeforeExecutionStart"},
+			{"host", "foo.com"},
+		}).Once()
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+
+		m.AssertExpectations(t)
+	})
+	t.Run("BeforeReadBody[No attempt segment]", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.TODO())
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m})
+		m.On("Printf", subsegmentNotStartedF, []interface{}{"BeforeReadBody", "foo.com"}).Once()
+
+		e.Request = e.Plan.ToRequest(context.TODO())
+		h.Handle(httpx.BeforeReadBody, e)
+
+		m.AssertExpectations(t)
+	})
+	t.Run("AfterAttemptTimeout[No attempt segment]", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.TODO())
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m})
+
+		e.Request = e.Plan.ToRequest(context.TODO())
+		h.Handle(httpx.AfterAttemptTimeout, e)
+
+		m.AssertExpectations(t)
+	})
 	t.Run("AfterExecutionEnd[No execution segment]", func(t *testing.T) {
 		e := newExecutionWithContext(t, context.TODO())
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 
 		h.Handle(httpx.AfterExecutionEnd, e)
 
 		m.AssertExpectations(t)
+		assert.Equal(t, uint64(1), h.stats.snapshot().MissingOnClose)
 	})
 	t.Run("AfterPlanTimeout[With execution segment]", func(t *testing.T) {
 		ctx, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 		e := newExecutionWithContext(t, ctx)
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 
 		h.Handle(httpx.AfterPlanTimeout, e)
 
@@ -96,6 +150,324 @@ func TestHandler_Handle(t *testing.T) {
 		require.Contains(t, seg.Metadata, "httpx")
 		assert.Equal(t, true, seg.Metadata["httpx"]["plan_timeout"])
 	})
+	t.Run("BeforeExecutionStart[Custom SegmentNamer]", func(t *testing.T) {
+		e := newExecutionWithContext(t, parentCtx)
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m, SegmentNamer: NewStaticNamer(map[string]string{"foo.com": "Foo"})})
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		defer h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		executionSeg := xray.GetSegment(e.Plan.Context())
+		require.NotNil(t, executionSeg)
+		assert.Equal(t, "Foo", executionSeg.Name)
+	})
+	t.Run("BeforeExecutionStart[SegmentNamer returns empty name]", func(t *testing.T) {
+		e := newExecutionWithContext(t, parentCtx)
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m, SegmentNamer: SegmentNamerFunc(func(_ *request.Plan) string {
+			return ""
+		})})
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		defer h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		executionSeg := xray.GetSegment(e.Plan.Context())
+		require.NotNil(t, executionSeg)
+		assert.Equal(t, "foo.com", executionSeg.Name)
+	})
+	t.Run("BeforeAttempt[URL sanitization]", func(t *testing.T) {
+		testCases := []struct {
+			name      string
+			sanitizer URLSanitizer
+			url       string
+		}{
+			{"default", nil, "http://foo.com/tokens/abc123"},
+			{"StripQuery", StripQuery, "http://u:p@foo.com/tokens/abc123"},
+			{"custom", ChainSanitizers(StripUserinfo, NewQueryAllowlist("a")), "http://foo.com/tokens/abc123?a=1"},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				p, err := request.NewPlanWithContext(parentCtx, "", "http://u:p@foo.com/tokens/abc123?a=1&b=2", nil)
+				require.NoError(t, err)
+				e := &request.Execution{Plan: p}
+				m := newMockLogger(t)
+				h := newHandler(Config{Logger: m, URLSanitizer: testCase.sanitizer})
+
+				h.Handle(httpx.BeforeExecutionStart, e)
+				e.Request = e.Plan.ToRequest(e.Plan.Context())
+				h.Handle(httpx.BeforeAttempt, e)
+				attemptSeg := xray.GetSegment(e.Request.Context())
+				require.NotNil(t, attemptSeg)
+				h.Handle(httpx.AfterAttempt, e)
+				h.Handle(httpx.AfterExecutionEnd, e)
+
+				m.AssertExpectations(t)
+				assert.Equal(t, testCase.url, attemptSeg.GetHTTP().GetRequest().URL)
+				assert.Equal(t, "http://u:p@foo.com/tokens/abc123?a=1&b=2", e.Request.URL.String())
+			})
+		}
+	})
+	t.Run("Attempt[Header capture]", func(t *testing.T) {
+		ctx, seg := newSampledSegment(t)
+		defer seg.Close(nil)
+		e := newExecutionWithContext(t, ctx)
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m, Headers: []HeaderRule{
+			{Source: RequestHeader, Name: "X-Request-Id"},
+			{Source: ResponseHeader, Name: "X-Cache"},
+		}})
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		e.Request.Header.Set("X-Request-Id", "abc")
+		h.Handle(httpx.BeforeAttempt, e)
+		attemptSeg := xray.GetSegment(e.Request.Context())
+		require.NotNil(t, attemptSeg)
+		require.False(t, attemptSeg.Dummy)
+		e.Response = &http.Response{StatusCode: 200, Header: http.Header{"X-Cache": []string{"HIT"}}}
+		h.Handle(httpx.AfterAttempt, e)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		assert.Equal(t, map[string]interface{}{
+			"request_x_request_id": "abc",
+			"response_x_cache":     "HIT",
+		}, attemptSeg.Annotations)
+	})
+	t.Run("Attempt[Custom Classifier]", func(t *testing.T) {
+		e := newExecutionWithContext(t, parentCtx)
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m, Classifier: TreatAsSuccess(nil, 404)})
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		attemptSeg := xray.GetSegment(e.Request.Context())
+		require.NotNil(t, attemptSeg)
+		e.Response = &http.Response{StatusCode: 404}
+		h.Handle(httpx.AfterAttempt, e)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		assert.Equal(t, 404, attemptSeg.GetHTTP().GetResponse().Status)
+		assert.False(t, attemptSeg.Error)
+		assert.False(t, attemptSeg.Fault)
+		executionSeg := xray.GetSegment(e.Plan.Context())
+		require.NotNil(t, executionSeg)
+		assert.False(t, executionSeg.Error)
+		assert.False(t, executionSeg.Fault)
+	})
+	t.Run("AfterPlanTimeout[FactsAsAnnotations]", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		e := newExecutionWithContext(t, ctx)
+		m := newMockLogger(t)
+		h := newHandler(Config{Logger: m, Facts: FactsAsAnnotations})
+
+		h.Handle(httpx.AfterPlanTimeout, e)
+
+		m.AssertExpectations(t)
+		assert.NotContains(t, seg.Metadata, "httpx")
+		assert.Equal(t, true, seg.Annotations["httpx_plan_timeout"])
+	})
+	t.Run("Attempt[Timeout]", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			deadline bool
+		}{
+			{"with deadline", true},
+			{"without deadline", false},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				e := newExecutionWithContext(t, parentCtx)
+				m := newMockLogger(t)
+				h := newHandler(Config{Logger: m})
+
+				h.Handle(httpx.BeforeExecutionStart, e)
+				ctx := e.Plan.Context()
+				if testCase.deadline {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, time.Hour)
+					defer cancel()
+				}
+				e.Request = e.Plan.ToRequest(ctx)
+				h.Handle(httpx.BeforeAttempt, e)
+				attemptSeg := xray.GetSegment(e.Request.Context())
+				require.NotNil(t, attemptSeg)
+				attemptSeg.Dummy = false
+				e.Err = &url.Error{Op: "Get", URL: "http://foo.com", Err: context.DeadlineExceeded}
+				e.Response = &http.Response{StatusCode: 404}
+				e.AttemptTimeouts++
+				h.Handle(httpx.AfterAttemptTimeout, e)
+				h.Handle(httpx.AfterAttempt, e)
+				h.Handle(httpx.AfterExecutionEnd, e)
+
+				m.AssertExpectations(t)
+				require.Contains(t, attemptSeg.Metadata, "httpx")
+				assert.Equal(t, true, attemptSeg.Metadata["httpx"]["attempt_timeout"])
+				if testCase.deadline {
+					require.Contains(t, attemptSeg.Metadata["httpx"], "timeout_ms")
+					timeoutMS := attemptSeg.Metadata["httpx"]["timeout_ms"]
+					assert.Equal(t, int64(60*60*1000), timeoutMS)
+				} else {
+					assert.NotContains(t, attemptSeg.Metadata["httpx"], "timeout_ms")
+				}
+				assert.True(t, attemptSeg.Fault)
+				assert.False(t, attemptSeg.Error)
+				require.NotNil(t, attemptSeg.Cause)
+				require.Len(t, attemptSeg.Cause.Exceptions, 2)
+				assert.Equal(t, "url.Error", attemptSeg.Cause.Exceptions[0].Type)
+				assert.Equal(t, "context.deadlineExceededError", attemptSeg.Cause.Exceptions[1].Type)
+				assert.Equal(t, string(ErrorKindAttemptTimeout), attemptSeg.Annotations[errorKindKey])
+			})
+		}
+	})
+	t.Run("Attempt[ReadBody]", func(t *testing.T) {
+		timeoutErr := &url.Error{Op: "Get", URL: "http://foo.com", Err: context.DeadlineExceeded}
+		testCases := []struct {
+			name       string
+			classifier Classifier
+			status     int
+			err        error
+			body       []byte
+			cls        Classification
+			cause      bool
+			key        string
+		}{
+			{"complete", nil, 200, nil, []byte("hello"), Classification{}, false, ""},
+			{"complete[503]", nil, 503, nil, []byte("oops"), Classification{}, false, ""},
+			{"timeout", nil, 200, timeoutErr, []byte("hel"), Classification{Fault: true}, true, "read_timeout"},
+			{"timeout[Custom Classifier]", TreatTimeoutAs(nil, Classification{Error: true}), 200, timeoutErr, []byte("hel"), Classification{Error: true}, true, "read_timeout"},
+			{"error", nil, 200, &url.Error{Op: "Get", URL: "http://foo.com", Err: errors.New("unexpected EOF")}, []byte("he"), Classification{Fault: true}, true, "read_error"},
+			{"cancelled", nil, 200, &url.Error{Op: "Get", URL: "http://foo.com", Err: racing.Redundant}, []byte("h"), Classification{}, false, "read_cancelled"},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				e := newExecutionWithContext(t, parentCtx)
+				m := newMockLogger(t)
+				h := newHandler(Config{Logger: m, Classifier: testCase.classifier})
+
+				h.Handle(httpx.BeforeExecutionStart, e)
+				e.Request = e.Plan.ToRequest(e.Plan.Context())
+				h.Handle(httpx.BeforeAttempt, e)
+				attemptSeg := xray.GetSegment(e.Request.Context())
+				require.NotNil(t, attemptSeg)
+				e.Response = &http.Response{StatusCode: testCase.status}
+				h.Handle(httpx.BeforeReadBody, e)
+				as, err := h.getAttemptState(e)
+				require.NoError(t, err)
+				require.NotNil(t, as.readBody)
+				readBodySeg := as.readBody.(xraySpan).seg
+				assert.Equal(t, "ReadBody", readBodySeg.Name)
+				assert.True(t, readBodySeg.InProgress)
+				readBodySeg.Dummy = false
+				e.Body = testCase.body
+				e.Err = testCase.err
+				h.Handle(httpx.AfterAttempt, e)
+
+				assert.False(t, readBodySeg.InProgress)
+				assert.False(t, attemptSeg.InProgress)
+				assert.LessOrEqual(t, readBodySeg.EndTime, attemptSeg.EndTime)
+				assert.Equal(t, testCase.cls.Fault, readBodySeg.Fault)
+				assert.Equal(t, testCase.cls.Error, readBodySeg.Error)
+				assert.Equal(t, testCase.cls.Throttle, readBodySeg.Throttle)
+				assert.Equal(t, testCase.cause, readBodySeg.Cause != nil)
+				require.Contains(t, readBodySeg.Metadata, "httpx")
+				assert.Equal(t, len(testCase.body), readBodySeg.Metadata["httpx"]["bytes_read"])
+				for _, key := range []string{"read_timeout", "read_error", "read_cancelled"} {
+					if key == testCase.key {
+						assert.Equal(t, true, readBodySeg.Metadata["httpx"][key])
+					} else {
+						assert.NotContains(t, readBodySeg.Metadata["httpx"], key)
+					}
+				}
+
+				h.Handle(httpx.AfterExecutionEnd, e)
+				m.AssertExpectations(t)
+			})
+		}
+	})
+	t.Run("Backoff", func(t *testing.T) {
+		root := &fakeSpan{name: "root"}
+		e := newExecutionWithContext(t, context.WithValue(context.Background(), fakeSpanKey, root))
+		m := newMockLogger(t)
+		ft := &fakeTracer{}
+		h := newHandler(Config{Logger: m, Backoff: true})
+		h.tracer = ft
+
+		h.recordBackoff(e)
+		assert.Empty(t, ft.spans, "no backoff before execution start")
+		h.Handle(httpx.BeforeExecutionStart, e)
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		e.Response = &http.Response{StatusCode: 503}
+		h.Handle(httpx.AfterAttempt, e)
+		attemptEnd := time.Now()
+		h.recordBackoff(e)
+		assert.Nil(t, ft.find("Backoff"), "no backoff within a wave")
+		time.Sleep(10 * time.Millisecond)
+		e.Attempt, e.Wave = 1, 1
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		e.Response = &http.Response{StatusCode: 200}
+		h.Handle(httpx.AfterAttempt, e)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		backoff := ft.find("Backoff")
+		require.NotNil(t, backoff)
+		assert.Same(t, ft.find("foo.com"), backoff.parent)
+		assert.True(t, backoff.closed)
+		assert.WithinDuration(t, attemptEnd, backoff.start, 5*time.Millisecond)
+		assert.GreaterOrEqual(t, backoff.end.Sub(backoff.start), 10*time.Millisecond)
+		n := len(ft.spans)
+		h.recordBackoff(e)
+		assert.Len(t, ft.spans, n, "backoff only recorded once per wave")
+	})
+	t.Run("AfterAttempt[Outcome]", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			status  int
+			err     error
+			outcome string
+			fault   bool
+		}{
+			{"succeeded", 200, nil, outcomeSucceeded, false},
+			{"failed[status]", 503, nil, outcomeFailed, true},
+			{"failed[error]", 0, errors.New("connection reset"), outcomeFailed, true},
+			{"cancelled_by_race", 0, &url.Error{Op: "Get", URL: "http://foo.com", Err: racing.Redundant}, outcomeCancelledByRace, false},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				e := newExecutionWithContext(t, parentCtx)
+				m := newMockLogger(t)
+				h := newHandler(Config{Logger: m})
+
+				h.Handle(httpx.BeforeExecutionStart, e)
+				e.Request = e.Plan.ToRequest(e.Plan.Context())
+				h.Handle(httpx.BeforeAttempt, e)
+				attemptSeg := xray.GetSegment(e.Request.Context())
+				require.NotNil(t, attemptSeg)
+				attemptSeg.Dummy = false
+				if testCase.status != 0 {
+					e.Response = &http.Response{StatusCode: testCase.status}
+					h.Handle(httpx.BeforeReadBody, e)
+				}
+				e.Err = testCase.err
+				h.Handle(httpx.AfterAttempt, e)
+				h.Handle(httpx.AfterExecutionEnd, e)
+
+				m.AssertExpectations(t)
+				assert.Equal(t, testCase.outcome, attemptSeg.Metadata["httpx"]["outcome"])
+				assert.Equal(t, testCase.fault, attemptSeg.Fault)
+				assert.False(t, attemptSeg.Error)
+			})
+		}
+	})
 	t.Run("incomplete flow", func(t *testing.T) {
 		// The purpose of this test is to make sure we can close the root
 		// execution segment even if there's an attempt segment that somehow
@@ -103,7 +475,7 @@ func TestHandler_Handle(t *testing.T) {
 		// AfterAttempt event handler panicked.
 		e := newExecutionWithContext(t, parentCtx)
 		m := newMockLogger(t)
-		h := &handler{m}
+		h := newHandler(Config{Logger: m})
 
 		h.Handle(httpx.BeforeExecutionStart, e)
 		e.Request = e.Plan.ToRequest(e.Plan.Context())
@@ -115,13 +487,13 @@ func TestHandler_Handle(t *testing.T) {
 		require.NotNil(t, executionSeg)
 		assert.Equal(t, "foo.com", executionSeg.Name)
 		assert.False(t, executionSeg.InProgress)
-		assert.True(t, executionSeg.ContextDone)
+		assert.False(t, executionSeg.ContextDone)
 	})
 	t.Run("full flow", func(t *testing.T) {
 		t.Run("serial[one attempt]", func(t *testing.T) {
 			e := newExecutionWithContext(t, parentCtx)
 			m := newMockLogger(t)
-			h := &handler{m}
+			h := newHandler(Config{Logger: m})
 
 			h.Handle(httpx.BeforeExecutionStart, e)
 
@@ -148,15 +520,48 @@ func TestHandler_Handle(t *testing.T) {
 
 			h.Handle(httpx.AfterExecutionEnd, e)
 			assert.False(t, executionSeg.InProgress)
-			assert.True(t, executionSeg.ContextDone)
+			assert.False(t, executionSeg.ContextDone)
 			assert.Greater(t, executionSeg.EndTime, 0.0)
 
 			m.AssertExpectations(t)
 		})
+		t.Run("serial[failed while connecting]", func(t *testing.T) {
+			e := newExecutionWithContext(t, parentCtx)
+			m := newMockLogger(t)
+			h := newHandler(Config{Logger: m})
+
+			h.Handle(httpx.BeforeExecutionStart, e)
+			e.Request = e.Plan.ToRequest(e.Plan.Context())
+			h.Handle(httpx.BeforeAttempt, e)
+			ct := httptrace.ContextClientTrace(e.Request.Context())
+			require.NotNil(t, ct)
+			ct.GetConn("foo.com:80")
+			ct.ConnectStart("tcp", "127.0.0.1:80")
+			e.Err = errors.New("connection refused")
+			h.Handle(httpx.AfterAttempt, e)
+			ct.ConnectDone("tcp", "127.0.0.1:80", nil)
+			h.Handle(httpx.AfterExecutionEnd, e)
+
+			m.AssertExpectations(t)
+			as, err := h.getAttemptState(e)
+			require.NoError(t, err)
+			trace := as.trace.(*xrayConnTrace)
+			for _, seg := range []*xray.Segment{trace.conn, trace.dial} {
+				require.NotNil(t, seg)
+				assert.False(t, seg.InProgress)
+				assert.True(t, seg.Fault)
+				require.NotNil(t, seg.Cause)
+				require.Len(t, seg.Cause.Exceptions, 1)
+				assert.Equal(t, "connection refused", seg.Cause.Exceptions[0].Message)
+			}
+			executionSeg := xray.GetSegment(e.Plan.Context())
+			assert.False(t, executionSeg.InProgress)
+			assert.False(t, executionSeg.ContextDone)
+		})
 		t.Run("serial[multiple attempts]", func(t *testing.T) {
 			e := newExecutionWithContext(t, parentCtx)
 			m := newMockLogger(t)
-			h := &handler{m}
+			h := newHandler(Config{Logger: m})
 
 			h.Handle(httpx.BeforeExecutionStart, e)
 
@@ -196,7 +601,7 @@ func TestHandler_Handle(t *testing.T) {
 
 			h.Handle(httpx.AfterExecutionEnd, e)
 			assert.False(t, executionSeg.InProgress)
-			assert.True(t, executionSeg.ContextDone)
+			assert.False(t, executionSeg.ContextDone)
 			assert.Greater(t, executionSeg.EndTime, 0.0)
 
 			m.AssertExpectations(t)
@@ -204,7 +609,7 @@ func TestHandler_Handle(t *testing.T) {
 		t.Run("racing[multiple attempts]", func(t *testing.T) {
 			e := newExecutionWithContext(t, parentCtx)
 			m := newMockLogger(t)
-			h := &handler{m}
+			h := newHandler(Config{Logger: m})
 
 			// EXECUTION: START
 			h.Handle(httpx.BeforeExecutionStart, e)
@@ -238,6 +643,7 @@ func TestHandler_Handle(t *testing.T) {
 			assert.Equal(t, 0.0, executionSeg.EndTime)
 			attempt1Seg := xray.GetSegment(req1.Context())
 			require.NotNil(t, attempt1Seg)
+			attempt0Seg.Dummy, attempt1Seg.Dummy = false, false
 			assert.Equal(t, "Attempt:1", attempt1Seg.Name)
 			assert.Empty(t, attempt1Seg.Namespace)
 			assert.True(t, attempt0Seg.InProgress)
@@ -259,6 +665,7 @@ func TestHandler_Handle(t *testing.T) {
 			assert.Equal(t, 400, attempt1Seg.GetHTTP().GetResponse().Status)
 			assert.True(t, attempt1Seg.Error)
 			assert.False(t, attempt1Seg.Fault)
+			assert.Equal(t, outcomeFailed, attempt1Seg.Metadata["httpx"]["outcome"])
 			assert.True(t, attempt0Seg.InProgress)
 			assert.Equal(t, 0.0, attempt0Seg.EndTime)
 
@@ -275,13 +682,15 @@ func TestHandler_Handle(t *testing.T) {
 			assert.GreaterOrEqual(t, attempt0Seg.EndTime, attempt1Seg.EndTime)
 			assert.Equal(t, 0, attempt0Seg.GetHTTP().GetResponse().Status)
 			assert.False(t, attempt0Seg.Error)
-			assert.True(t, attempt0Seg.Fault)
+			assert.False(t, attempt0Seg.Fault)
+			assert.Nil(t, attempt0Seg.Cause)
+			assert.Equal(t, outcomeCancelledByRace, attempt0Seg.Metadata["httpx"]["outcome"])
 
 			// Execution: END
 			e.Err = nil
 			h.Handle(httpx.AfterExecutionEnd, e)
 			assert.False(t, executionSeg.InProgress)
-			assert.True(t, executionSeg.ContextDone)
+			assert.False(t, executionSeg.ContextDone)
 			assert.Greater(t, executionSeg.EndTime, 0.0)
 			assert.False(t, executionSeg.Error)
 			assert.False(t, executionSeg.Fault)
@@ -300,6 +709,20 @@ func newExecutionWithContext(t *testing.T, ctx context.Context) *request.Executi
 	}
 }
 
+func TestExecutionState_raced(t *testing.T) {
+	es := &executionState{}
+	assert.False(t, es.raced(0))
+	es.countAttempt(0)
+	assert.False(t, es.raced(0))
+	es.countAttempt(1)
+	assert.False(t, es.raced(0))
+	assert.False(t, es.raced(1))
+	es.countAttempt(1)
+	assert.True(t, es.raced(1))
+	es.countAttempt(2)
+	assert.False(t, es.raced(2))
+}
+
 func TestHost(t *testing.T) {
 	p := &request.Plan{}
 	p.Host = "foo"
@@ -319,61 +742,36 @@ func TestSetSegmentHTTPResponse(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentHTTPResponse(seg, nil)
+		setSegmentHTTPResponse(xraySpan{seg}, nil)
 
-		assert.False(t, seg.Error)
-		assert.False(t, seg.Fault)
-		assert.False(t, seg.Throttle)
+		assert.Nil(t, seg.HTTP)
 	})
-	t.Run("OK", func(t *testing.T) {
+	t.Run("No Content-Length", func(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentHTTPResponse(seg, &http.Response{StatusCode: 200})
+		setSegmentHTTPResponse(xraySpan{seg}, &http.Response{StatusCode: 200})
 
+		assert.Equal(t, 200, seg.GetHTTP().GetResponse().Status)
+		assert.Equal(t, 0, seg.GetHTTP().GetResponse().ContentLength)
 		assert.False(t, seg.Error)
 		assert.False(t, seg.Fault)
 		assert.False(t, seg.Throttle)
 	})
-	t.Run("Error.4XX", func(t *testing.T) {
-		statusCodes := []int{400, 401, 403, 404, 405, 406, 409}
-		for _, statusCode := range statusCodes {
-			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
-				_, seg := newNonDummySegment(t)
-				defer seg.Close(nil)
-
-				setSegmentHTTPResponse(seg, &http.Response{StatusCode: statusCode})
-
-				assert.True(t, seg.Error)
-				assert.False(t, seg.Fault)
-				assert.False(t, seg.Throttle)
-			})
-		}
-	})
-	t.Run("Error.429", func(t *testing.T) {
+	t.Run("With Content-Length", func(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentHTTPResponse(seg, &http.Response{StatusCode: 429})
+		setSegmentHTTPResponse(xraySpan{seg}, &http.Response{
+			StatusCode: 503,
+			Header:     http.Header{"Content-Length": []string{"17"}},
+		})
 
-		assert.True(t, seg.Error)
+		assert.Equal(t, 503, seg.GetHTTP().GetResponse().Status)
+		assert.Equal(t, 17, seg.GetHTTP().GetResponse().ContentLength)
+		assert.False(t, seg.Error)
 		assert.False(t, seg.Fault)
-		assert.True(t, seg.Throttle)
-	})
-	t.Run("Fault.5XX", func(t *testing.T) {
-		statusCodes := []int{500, 502, 503, 504, 505}
-		for _, statusCode := range statusCodes {
-			t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
-				_, seg := newNonDummySegment(t)
-				defer seg.Close(nil)
-
-				setSegmentHTTPResponse(seg, &http.Response{StatusCode: statusCode})
-
-				assert.False(t, seg.Error)
-				assert.True(t, seg.Fault)
-				assert.False(t, seg.Throttle)
-			})
-		}
+		assert.False(t, seg.Throttle)
 	})
 }
 
@@ -382,7 +780,7 @@ func TestSetSegmentBodyLen(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentBodyLen(seg, nil)
+		setSegmentBodyLen(xraySpan{seg}, FactsAsMetadata, nil)
 
 		assert.NotContains(t, "httpx", seg.Metadata)
 	})
@@ -390,7 +788,7 @@ func TestSetSegmentBodyLen(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentBodyLen(seg, []byte{})
+		setSegmentBodyLen(xraySpan{seg}, FactsAsMetadata, []byte{})
 
 		require.Contains(t, seg.Metadata, "httpx")
 		require.Contains(t, seg.Metadata["httpx"], "body_length")
@@ -400,7 +798,7 @@ func TestSetSegmentBodyLen(t *testing.T) {
 		_, seg := newNonDummySegment(t)
 		defer seg.Close(nil)
 
-		setSegmentBodyLen(seg, []byte("foo"))
+		setSegmentBodyLen(xraySpan{seg}, FactsAsMetadata, []byte("foo"))
 
 		require.Contains(t, seg.Metadata, "httpx")
 		require.Contains(t, seg.Metadata["httpx"], "body_length")
@@ -412,7 +810,7 @@ func TestSetSegmentExecutionMetadata(t *testing.T) {
 	_, seg := newNonDummySegment(t)
 	defer seg.Close(nil)
 
-	setSegmentExecutionMetadata(seg, 31, 33)
+	setSegmentExecutionMetadata(xraySpan{seg}, FactsAsMetadata, 31, 33)
 
 	require.Contains(t, seg.Metadata, "httpx")
 	require.Contains(t, seg.Metadata["httpx"], "attempts")
@@ -421,69 +819,104 @@ func TestSetSegmentExecutionMetadata(t *testing.T) {
 	assert.Equal(t, 33, seg.Metadata["httpx"]["waves"])
 }
 
+func TestSetSegmentExecutionMetadata_Annotations(t *testing.T) {
+	_, seg := newNonDummySegment(t)
+	defer seg.Close(nil)
+
+	setSegmentExecutionMetadata(xraySpan{seg}, FactsAsAnnotations, 2, 1)
+	setSegmentBodyLen(xraySpan{seg}, FactsAsAnnotations, []byte("foo"))
+
+	assert.Equal(t, map[string]interface{}{
+		"httpx_attempts":    2,
+		"httpx_waves":       1,
+		"httpx_body_length": 3,
+	}, seg.Annotations)
+}
+
 func TestSetSegmentAttemptMetadata(t *testing.T) {
 	_, seg := newNonDummySegment(t)
 	defer seg.Close(nil)
 
-	setSegmentAttemptMetadata(seg, 109)
+	setSegmentAttemptMetadata(xraySpan{seg}, FactsAsMetadata, 109, 17)
 
 	require.Contains(t, seg.Metadata, "httpx")
 	require.Contains(t, seg.Metadata["httpx"], "attempt")
 	assert.Equal(t, 109, seg.Metadata["httpx"]["attempt"])
+	require.Contains(t, seg.Metadata["httpx"], "wave")
+	assert.Equal(t, 17, seg.Metadata["httpx"]["wave"])
 }
 
 func TestPutAttemptState(t *testing.T) {
 	t.Run("No attempt skip", func(t *testing.T) {
 		e := &request.Execution{}
+		h := &handler{}
 
-		httpSubsegments := &xray.HTTPSubsegments{}
-		putAttemptState(e, attemptState{httpSubsegments: httpSubsegments})
-		as, err := getAttemptState(e)
+		trace := &xrayConnTrace{}
+		h.putAttemptState(e, attemptState{trace: trace})
+		as, err := h.getAttemptState(e)
 
 		require.NoError(t, err)
-		assert.Same(t, httpSubsegments, as.httpSubsegments)
+		assert.Same(t, trace, as.trace)
 	})
 	t.Run("With attempt skip", func(t *testing.T) {
 		e := &request.Execution{Attempt: 1}
+		h := &handler{}
 
-		httpSubsegments := &xray.HTTPSubsegments{}
-		putAttemptState(e, attemptState{httpSubsegments: httpSubsegments})
+		trace := &xrayConnTrace{}
+		h.putAttemptState(e, attemptState{trace: trace})
 		e.Attempt = 0
-		as0, err0 := getAttemptState(e)
+		as0, err0 := h.getAttemptState(e)
 		e.Attempt = 1
-		as1, err1 := getAttemptState(e)
+		as1, err1 := h.getAttemptState(e)
 
 		require.NoError(t, err0)
-		assert.Nil(t, as0.httpSubsegments)
+		assert.Nil(t, as0.trace)
 		require.NoError(t, err1)
-		assert.Same(t, httpSubsegments, as1.httpSubsegments)
+		assert.Same(t, trace, as1.trace)
 	})
 	t.Run("Modify value", func(t *testing.T) {
 		e := &request.Execution{}
+		h := &handler{}
 
-		httpSubsegmentsBefore := &xray.HTTPSubsegments{}
-		httpSubsegmentsAfter := &xray.HTTPSubsegments{}
-		putAttemptState(e, attemptState{httpSubsegments: httpSubsegmentsBefore})
-		asBefore, errBefore := getAttemptState(e)
-		putAttemptState(e, attemptState{httpSubsegments: httpSubsegmentsAfter})
-		asAfter, errAfter := getAttemptState(e)
+		traceBefore := &xrayConnTrace{}
+		traceAfter := &xrayConnTrace{}
+		h.putAttemptState(e, attemptState{trace: traceBefore})
+		asBefore, errBefore := h.getAttemptState(e)
+		h.putAttemptState(e, attemptState{trace: traceAfter})
+		asAfter, errAfter := h.getAttemptState(e)
 
 		require.NoError(t, errBefore)
-		assert.Same(t, httpSubsegmentsBefore, asBefore.httpSubsegments)
+		assert.Same(t, traceBefore, asBefore.trace)
 		require.NoError(t, errAfter)
-		assert.Same(t, httpSubsegmentsAfter, asAfter.httpSubsegments)
+		assert.Same(t, traceAfter, asAfter.trace)
+	})
+	t.Run("Separate handlers", func(t *testing.T) {
+		e := &request.Execution{}
+		h1, h2 := &handler{}, &handler{}
+
+		trace := &xrayConnTrace{}
+		h1.putAttemptState(e, attemptState{trace: trace})
+		as1, err1 := h1.getAttemptState(e)
+		_, err2 := h2.getAttemptState(e)
+
+		require.NoError(t, err1)
+		assert.Same(t, trace, as1.trace)
+		assert.Error(t, err2)
 	})
 }
 
-func getAttemptState(e *request.Execution) (attemptState, error) {
-	es, _ := e.Value(executionStateKey).(*executionState)
-	if es == nil {
-		return attemptState{}, errors.New("httpxxray: no execution state")
-	}
-	if len(es.as) <= e.Attempt {
-		return attemptState{}, fmt.Errorf("httpxxray: no attempt state %d", e.Attempt)
-	}
-	return es.as[e.Attempt], nil
+// newSampledSegment begins a sampled X-Ray segment. Unlike with the
+// simulated Lambda parent context, subsegments begun under a sampled
+// segment are not dummies, so annotations and metadata added to them
+// are retained.
+func newSampledSegment(t *testing.T) (context.Context, *xray.Segment) {
+	ctx, seg := xray.BeginSegment(context.Background(), "test")
+	require.NotNil(t, ctx)
+	require.NotNil(t, seg)
+	seg.Lock()
+	defer seg.Unlock()
+	seg.Sampled, seg.Dummy = true, false
+	return ctx, seg
 }
 
 func newNonDummySegment(t *testing.T) (context.Context, *xray.Segment) {
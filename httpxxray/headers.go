@@ -0,0 +1,137 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderSource identifies whether a HeaderRule captures a header from
+// the HTTP request or from the HTTP response.
+type HeaderSource int
+
+const (
+	// RequestHeader indicates a header sent on the HTTP request. Request
+	// headers are captured when each request attempt starts.
+	RequestHeader HeaderSource = iota
+	// ResponseHeader indicates a header received on the HTTP response.
+	// Response headers are captured when each request attempt ends,
+	// provided a response was received.
+	ResponseHeader
+)
+
+// HeaderTarget identifies where on the X-Ray attempt subsegment a
+// HeaderRule records the captured header value.
+type HeaderTarget int
+
+const (
+	// Annotation records the header value as an X-Ray annotation, which
+	// is indexed and can be used in X-Ray filter expressions.
+	Annotation HeaderTarget = iota
+	// Metadata records the header value as X-Ray metadata in the
+	// "httpx_headers" namespace. Metadata is not indexed.
+	//
+	// Header metadata is kept apart from the "httpx" namespace used
+	// for the plugin's own facts, so a header key can never overwrite
+	// a fact such as "attempt" or "outcome".
+	Metadata
+)
+
+// A HeaderRule captures one HTTP header onto the attempt subsegment so
+// that its value appears in the X-Ray trace.
+//
+// If the header is absent, nothing is recorded. If the header has
+// multiple values, they are joined with a comma, in the manner of
+// RFC 7230 section 3.2.2.
+type HeaderRule struct {
+	// Source indicates whether the header is read from the request or
+	// from the response.
+	Source HeaderSource
+
+	// Name is the name of the header to capture. Matching is
+	// case-insensitive.
+	Name string
+
+	// Key is the annotation or metadata key under which the value is
+	// recorded. If empty, a key is derived from Source and Name, for
+	// example "request_x_request_id" for the X-Request-Id request
+	// header, or "response_x_cache" for the X-Cache response header.
+	//
+	// Because X-Ray only supports filtering on annotation keys made of
+	// alphanumeric characters and underscores, any other character in
+	// an explicitly provided Key is replaced with an underscore when
+	// recording an annotation.
+	Key string
+
+	// Target indicates whether to record the value as an annotation
+	// or as metadata.
+	Target HeaderTarget
+
+	// Redact, if not nil, transforms the header value before it is
+	// recorded. Use it to mask sensitive values. See also Redacted.
+	Redact func(value string) string
+}
+
+// Redacted is a HeaderRule redaction function which replaces the entire
+// header value with a fixed placeholder, recording only the fact that
+// the header was present.
+func Redacted(_ string) string {
+	return "REDACTED"
+}
+
+const headerMetadataNamespace = "httpx_headers"
+
+func (r *HeaderRule) key() string {
+	if r.Key == "" {
+		name := strings.ToLower(r.Name)
+		if r.Source == ResponseHeader {
+			return annotationKey("response_" + name)
+		}
+		return annotationKey("request_" + name)
+	}
+	if r.Target == Annotation {
+		return annotationKey(r.Key)
+	}
+	return r.Key
+}
+
+func annotationKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, k)
+}
+
+func captureHeaders(s Span, rules []HeaderRule, src HeaderSource, h http.Header) {
+	if h == nil {
+		return
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.Source != src {
+			continue
+		}
+		values := h.Values(r.Name)
+		if len(values) == 0 {
+			continue
+		}
+		v := strings.Join(values, ",")
+		if r.Redact != nil {
+			v = r.Redact(v)
+		}
+		switch r.Target {
+		case Metadata:
+			s.AddMetadata(headerMetadataNamespace, r.key(), v)
+		default:
+			s.AddAnnotation(r.key(), v)
+		}
+	}
+}
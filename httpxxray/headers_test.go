@@ -0,0 +1,107 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderRule_key(t *testing.T) {
+	testCases := []struct {
+		name string
+		rule HeaderRule
+		key  string
+	}{
+		{"Request[Derived]", HeaderRule{Source: RequestHeader, Name: "X-Request-Id"}, "request_x_request_id"},
+		{"Response[Derived]", HeaderRule{Source: ResponseHeader, Name: "X-Cache"}, "response_x_cache"},
+		{"Metadata[Derived]", HeaderRule{Name: "X-Tenant", Target: Metadata}, "request_x_tenant"},
+		{"Annotation[Explicit]", HeaderRule{Name: "X-Tenant", Key: "Tenant.Id"}, "Tenant_Id"},
+		{"Metadata[Explicit]", HeaderRule{Name: "X-Tenant", Key: "Tenant.Id", Target: Metadata}, "Tenant.Id"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.key, testCase.rule.key())
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	assert.Equal(t, "REDACTED", Redacted("secret"))
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	rules := []HeaderRule{
+		{Source: RequestHeader, Name: "X-Request-Id"},
+		{Source: RequestHeader, Name: "Authorization", Target: Metadata, Redact: Redacted},
+		{Source: ResponseHeader, Name: "x-cache", Key: "cache"},
+		{Source: ResponseHeader, Name: "Vary", Target: Metadata},
+	}
+	t.Run("nil header", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		captureHeaders(xraySpan{seg}, rules, RequestHeader, nil)
+
+		assert.Empty(t, seg.Annotations)
+		assert.Empty(t, seg.Metadata)
+	})
+	t.Run("Request", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		h := http.Header{}
+		h.Set("X-Request-Id", "abc")
+		h.Set("Authorization", "Bearer xyz")
+		h.Set("X-Cache", "HIT")
+
+		captureHeaders(xraySpan{seg}, rules, RequestHeader, h)
+
+		assert.Equal(t, map[string]interface{}{"request_x_request_id": "abc"}, seg.Annotations)
+		assert.NotContains(t, seg.Metadata, "httpx")
+		require.Contains(t, seg.Metadata, "httpx_headers")
+		assert.Equal(t, map[string]interface{}{"request_authorization": "REDACTED"}, seg.Metadata["httpx_headers"])
+	})
+	t.Run("Response", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		h := http.Header{}
+		h.Set("X-Request-Id", "abc")
+		h.Set("X-Cache", "MISS")
+		h.Add("Vary", "Accept")
+		h.Add("Vary", "Accept-Encoding")
+
+		captureHeaders(xraySpan{seg}, rules, ResponseHeader, h)
+
+		assert.Equal(t, map[string]interface{}{"cache": "MISS"}, seg.Annotations)
+		assert.NotContains(t, seg.Metadata, "httpx")
+		require.Contains(t, seg.Metadata, "httpx_headers")
+		assert.Equal(t, map[string]interface{}{"response_vary": "Accept,Accept-Encoding"}, seg.Metadata["httpx_headers"])
+	})
+	t.Run("Reserved key", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		s := xraySpan{seg}
+		addFact(s, FactsAsMetadata, "outcome", outcomeFailed)
+		h := http.Header{}
+		h.Set("X-Outcome", "spoofed")
+
+		captureHeaders(s, []HeaderRule{{Source: ResponseHeader, Name: "X-Outcome", Key: "outcome", Target: Metadata}}, ResponseHeader, h)
+
+		assert.Equal(t, outcomeFailed, seg.Metadata["httpx"]["outcome"])
+		assert.Equal(t, "spoofed", seg.Metadata["httpx_headers"]["outcome"])
+	})
+	t.Run("Absent", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		captureHeaders(xraySpan{seg}, rules, ResponseHeader, http.Header{})
+
+		assert.Empty(t, seg.Annotations)
+		assert.Empty(t, seg.Metadata)
+	})
+}
@@ -4,7 +4,13 @@
 
 package httpxxray
 
-import "github.com/gogama/httpx"
+import (
+	"reflect"
+	"sync"
+
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+)
 
 const (
 	nilClientMsg       = "httpxxray: nil client"
@@ -17,8 +23,13 @@ const (
 // handler group, sets it as client's current handler group, and
 // proceeds to install X-Ray support into the handler group. If the
 // handler group is not nil, OnClient adds X-Ray support into the
-// existing handler group. (Be aware of this behavior if you are sharing
-// a handler group among multiple clients.)
+// existing handler group. If the plugin is already installed on the
+// handler group, it is reconfigured instead (see Plugin), so the handler
+// group may safely be shared among multiple clients.
+//
+// When built with a Go version before 1.24, the plugin keeps every
+// handler group it is installed on reachable for the life of the
+// program (see Install).
 //
 // Logger is used to log errors encountered by the plugin. The plugin
 // does not produce any log messages in the ordinary course of operation
@@ -27,6 +38,28 @@ const (
 // NopLogger). However if you are using the plugin in a production
 // system it is always prudent to use a viable logger.
 func OnClient(client *httpx.Client, logger Logger) *httpx.Client {
+	return OnClientWithOptions(client, WithLogger(logger))
+}
+
+// OnClientWithOptions installs AWS X-Ray support onto an httpx Client,
+// customizing the plugin's behavior according to the given options.
+//
+// OnClientWithOptions behaves identically to OnClient as regards the
+// client's handler group. Calling OnClientWithOptions with only a
+// WithLogger option is equivalent to calling OnClient.
+func OnClientWithOptions(client *httpx.Client, opts ...Option) *httpx.Client {
+	InstallOnClient(client, opts...)
+
+	return client
+}
+
+// InstallOnClient installs AWS X-Ray support onto an httpx Client,
+// customizing the plugin's behavior according to the given options, and
+// returns the installed plugin.
+//
+// InstallOnClient behaves identically to OnClientWithOptions, except
+// for its return value.
+func InstallOnClient(client *httpx.Client, opts ...Option) *Plugin {
 	if client == nil {
 		panic(nilClientMsg)
 	}
@@ -37,15 +70,17 @@ func OnClient(client *httpx.Client, logger Logger) *httpx.Client {
 		client.Handlers = handlers
 	}
 
-	OnHandlers(handlers, logger)
-
-	return client
+	return Install(handlers, opts...)
 }
 
 // OnHandlers installs AWS X-Ray support onto an httpx HandlerGroup.
 //
 // The handler group may not be nil - if it is, a panic will ensue.
 //
+// When built with a Go version before 1.24, the plugin keeps every
+// handler group it is installed on reachable for the life of the
+// program (see Install).
+//
 // Logger is used to log errors encountered by the plugin. The plugin
 // does not produce any log messages in the ordinary course of operation
 // and the logger is intended as a "just in case" debugging aid. To
@@ -53,20 +88,180 @@ func OnClient(client *httpx.Client, logger Logger) *httpx.Client {
 // NopLogger). However if you are using the plugin in a production
 // system it is always prudent to use a viable logger.
 func OnHandlers(handlers *httpx.HandlerGroup, logger Logger) *httpx.HandlerGroup {
+	return OnHandlersWithOptions(handlers, WithLogger(logger))
+}
+
+// OnHandlersWithOptions installs AWS X-Ray support onto an httpx
+// HandlerGroup, customizing the plugin's behavior according to the
+// given options.
+//
+// The handler group may not be nil - if it is, a panic will ensue.
+// Calling OnHandlersWithOptions with only a WithLogger option is
+// equivalent to calling OnHandlers.
+func OnHandlersWithOptions(handlers *httpx.HandlerGroup, opts ...Option) *httpx.HandlerGroup {
+	Install(handlers, opts...)
+
+	return handlers
+}
+
+// Install installs AWS X-Ray support onto an httpx HandlerGroup,
+// customizing the plugin's behavior according to the given options, and
+// returns the installed plugin.
+//
+// Install behaves identically to OnHandlersWithOptions, except for its
+// return value.
+//
+// When built with a Go version before 1.24, which lacks weak pointers,
+// the plugin remembers every handler group it is installed on by
+// keeping a strong reference to it, so the handler group, and its
+// Plugin, stay reachable for the life of the program, even after
+// Remove. A program which installs the plugin on new handler groups
+// without bound, for example on a new client for each request or
+// tenant, therefore leaks memory. To avoid this, install the plugin
+// once on a handler group shared by all such clients, or build with Go
+// 1.24 or later, where the plugin forgets each handler group once it is
+// no longer otherwise reachable.
+func Install(handlers *httpx.HandlerGroup, opts ...Option) *Plugin {
 	if handlers == nil {
 		panic(nilHandlerGroupMsg)
 	}
 
-	if logger == nil {
-		logger = NopLogger{}
+	installLock.Lock()
+	defer installLock.Unlock()
+
+	c := newConfig(opts)
+	h := newHandler(c)
+	backend := backendOf(c.Tracer)
+	p := lookupPlugin(handlers, backend)
+	if p != nil {
+		p.setHandler(h)
+		return p
 	}
 
-	handler := &handler{logger}
-	handlers.PushBack(httpx.BeforeExecutionStart, handler)
-	handlers.PushBack(httpx.BeforeAttempt, handler)
-	handlers.PushBack(httpx.AfterAttempt, handler)
-	handlers.PushBack(httpx.AfterPlanTimeout, handler)
-	handlers.PushBack(httpx.AfterExecutionEnd, handler)
+	p = &Plugin{stats: h.stats}
+	p.setHandler(h)
+	c.Placement.place(handlers, p)
+	storePlugin(handlers, backend, p)
 
-	return handlers
+	return p
+}
+
+// Remove removes AWS X-Ray support from an httpx HandlerGroup, and
+// reports whether the plugin was installed on it. Plugins installed on
+// the handler group with a different tracing backend, such as the
+// OpenTelemetry plugin installed by package httpxotel, are not removed.
+//
+// Request plan executions which started before Remove was called are
+// traced to completion. Since an httpx HandlerGroup does not support
+// removing event handlers, the plugin's handlers remain in the handler
+// group but do nothing. Installing the plugin onto the handler group
+// again reactivates them.
+func Remove(handlers *httpx.HandlerGroup) bool {
+	return RemoveTracer(handlers, nil)
+}
+
+// RemoveTracer removes the plugin installed on an httpx HandlerGroup
+// with the same tracing backend as t, that is with a Tracer of the same
+// type as t, and reports whether such a plugin was installed on it. A
+// nil Tracer selects the default X-Ray backend, so RemoveTracer(handlers,
+// nil) is equivalent to Remove(handlers).
+//
+// RemoveTracer is intended for packages which provide a Tracer, such as
+// httpxotel, to implement their own Remove function.
+func RemoveTracer(handlers *httpx.HandlerGroup, t Tracer) bool {
+	if handlers == nil {
+		panic(nilHandlerGroupMsg)
+	}
+
+	installLock.Lock()
+	defer installLock.Unlock()
+
+	p := lookupPlugin(handlers, backendOf(t))
+	if p == nil || p.currentHandler() == nil {
+		return false
+	}
+
+	p.setHandler(nil)
+	return true
+}
+
+// backendOf identifies the tracing backend of the Tracer t by its type.
+// A nil Tracer is the default X-Ray backend.
+func backendOf(t Tracer) reflect.Type {
+	if t == nil {
+		t = xrayTracer{}
+	}
+	return reflect.TypeOf(t)
+}
+
+// installLock serializes changes to the plugins installed on handler
+// groups.
+var installLock sync.Mutex
+
+// A Plugin is an instance of the X-Ray plugin installed onto an httpx
+// HandlerGroup.
+//
+// The plugin is installed at most once on each handler group for each
+// tracing backend. Installing it again with the same backend, using any
+// of the installation functions, reconfigures the existing plugin
+// according to the new options, instead of adding another set of event
+// handlers, and returns the existing Plugin. Request plan executions
+// which started before the plugin was reconfigured are traced to
+// completion using the previous options.
+//
+// Installing the plugin with a different backend, for example installing
+// both this package's X-Ray plugin and the OpenTelemetry plugin of
+// package httpxotel, adds an independent Plugin, so that each request
+// plan execution is traced by both backends, as may be useful while
+// migrating from one to the other. Each Plugin sends its own trace
+// header, and if both send the same header, the Plugin whose handlers
+// run last wins.
+type Plugin struct {
+	stats *counters
+
+	lock    sync.RWMutex
+	handler *handler
+}
+
+// Stats returns a snapshot of the plugin's health counters. The counters
+// are kept when the plugin is reconfigured or removed.
+func (p *Plugin) Stats() Stats {
+	return p.stats.snapshot()
+}
+
+// Handle implements httpx.Handler. The plugin's configuration, and
+// whether it is installed at all, is fixed for each request plan
+// execution when the execution starts. An execution whose plan context
+// was made using WithoutTracing is ignored entirely.
+func (p *Plugin) Handle(evt httpx.Event, e *request.Execution) {
+	var h *handler
+	if evt == httpx.BeforeExecutionStart {
+		h = p.currentHandler()
+		if h == nil || tracingDisabled(e.Plan.Context()) {
+			return
+		}
+		e.SetValue(p, h)
+	} else if h, _ = e.Value(p).(*handler); h == nil {
+		return
+	}
+
+	h.Handle(evt, e)
+}
+
+func (p *Plugin) currentHandler() *handler {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.handler
+}
+
+// setHandler sets the handler for new executions. A nil handler means
+// the plugin is removed.
+func (p *Plugin) setHandler(h *handler) {
+	if h != nil {
+		h.stats = p.stats
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.handler = h
 }
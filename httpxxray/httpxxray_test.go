@@ -5,6 +5,7 @@
 package httpxxray
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -61,6 +62,74 @@ func TestOnHandlers(t *testing.T) {
 	})
 }
 
+func TestOnClientWithOptions(t *testing.T) {
+	t.Run("nil Client", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilClientMsg, func() {
+			OnClientWithOptions(nil)
+		})
+	})
+	t.Run("no options", func(t *testing.T) {
+		cl := &httpx.Client{}
+		OnClientWithOptions(cl)
+		assert.NotNil(t, cl.Handlers)
+	})
+	t.Run("everything", func(t *testing.T) {
+		cl := &httpx.Client{
+			Handlers: &httpx.HandlerGroup{},
+		}
+		OnClientWithOptions(cl, WithConfig(Config{}), WithLogger(&NopLogger{}))
+	})
+}
+
+func TestOnHandlersWithOptions(t *testing.T) {
+	t.Run("nil HandlerGroup", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			OnHandlersWithOptions(nil)
+		})
+	})
+	t.Run("no options", func(t *testing.T) {
+		h := &httpx.HandlerGroup{}
+		OnHandlersWithOptions(h)
+	})
+	t.Run("everything", func(t *testing.T) {
+		h := &httpx.HandlerGroup{}
+		OnHandlersWithOptions(h, WithConfig(Config{}), WithLogger(&NopLogger{}))
+	})
+}
+
+func TestInstallOnClient(t *testing.T) {
+	t.Run("nil Client", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilClientMsg, func() {
+			InstallOnClient(nil)
+		})
+	})
+	t.Run("client has nil Handlers", func(t *testing.T) {
+		cl := &httpx.Client{}
+
+		p := InstallOnClient(cl)
+
+		assert.NotNil(t, cl.Handlers)
+		require.NotNil(t, p)
+		assert.Equal(t, Stats{NotStarted: map[httpx.Event]uint64{}}, p.Stats())
+	})
+}
+
+func TestInstall(t *testing.T) {
+	t.Run("nil HandlerGroup", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			Install(nil)
+		})
+	})
+	t.Run("everything", func(t *testing.T) {
+		h := &httpx.HandlerGroup{}
+
+		p := Install(h, WithLogger(&NopLogger{}))
+
+		require.NotNil(t, p)
+		assert.NotNil(t, p.handler)
+	})
+}
+
 func TestIntegration(t *testing.T) {
 	for _, server := range servers {
 		t.Run(serverName(server), func(t *testing.T) {
@@ -105,7 +174,7 @@ func TestIntegration(t *testing.T) {
 					),
 				}
 				m := newMockLogger(t)
-				OnClient(cl, m)
+				pl := InstallOnClient(cl, WithLogger(m))
 				inst := serverInstruction{StatusCode: 429, Body: []bodyChunk{
 					{Data: []byte(`I so busy`)},
 				}}
@@ -116,6 +185,11 @@ func TestIntegration(t *testing.T) {
 				m.AssertExpectations(t)
 				require.NotNil(t, e)
 				require.NoError(t, err)
+				assert.Equal(t, Stats{
+					ExecutionsTraced: 1,
+					AttemptsTraced:   2,
+					NotStarted:       map[httpx.Event]uint64{},
+				}, pl.Stats())
 
 				seg := xray.GetSegment(e.Plan.Context())
 				require.NotNil(t, seg)
@@ -182,3 +256,113 @@ func TestIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestPlugin(t *testing.T) {
+	rootCtx := func() context.Context {
+		return context.WithValue(context.Background(), fakeSpanKey, &fakeSpan{name: "root"})
+	}
+	do := func(t *testing.T, cl *httpx.Client) {
+		inst := serverInstruction{StatusCode: 200}
+		_, err := cl.Do(inst.toPlan(rootCtx(), "", httpServer))
+		require.NoError(t, err)
+	}
+	countExecutions := func(ft *fakeTracer) int {
+		ft.lock.Lock()
+		defer ft.lock.Unlock()
+		n := 0
+		for _, s := range ft.spans {
+			if s.kind == ExecutionSpan {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("install twice", func(t *testing.T) {
+		ft1, ft2 := &fakeTracer{}, &fakeTracer{}
+		cl := &httpx.Client{HTTPDoer: httpServer.Client()}
+
+		p1 := InstallOnClient(cl, WithTracer(ft1))
+		OnClientWithOptions(cl, WithTracer(ft2))
+		p2 := Install(cl.Handlers, WithTracer(ft2))
+		do(t, cl)
+
+		assert.Same(t, p1, p2)
+		assert.Equal(t, 0, countExecutions(ft1))
+		assert.Equal(t, 1, countExecutions(ft2))
+		assert.Equal(t, uint64(1), p1.Stats().ExecutionsTraced)
+	})
+	t.Run("Remove", func(t *testing.T) {
+		ft := &fakeTracer{}
+		cl := &httpx.Client{HTTPDoer: httpServer.Client()}
+		p1 := InstallOnClient(cl, WithTracer(ft))
+
+		assert.True(t, RemoveTracer(cl.Handlers, ft))
+		assert.False(t, RemoveTracer(cl.Handlers, ft))
+		do(t, cl)
+		assert.Equal(t, 0, countExecutions(ft))
+
+		p2 := InstallOnClient(cl, WithTracer(ft))
+		do(t, cl)
+		assert.Same(t, p1, p2)
+		assert.Equal(t, 1, countExecutions(ft))
+	})
+	t.Run("two backends", func(t *testing.T) {
+		ft := &fakeTracer{}
+		g := &httpx.HandlerGroup{}
+		xp := Install(g)
+		fp := Install(g, WithTracer(ft))
+		cl := &httpx.Client{HTTPDoer: httpServer.Client(), Handlers: g}
+
+		assert.NotSame(t, xp, fp)
+		assert.Same(t, xp, Install(g))
+		assert.Same(t, fp, Install(g, WithTracer(&fakeTracer{})))
+		assert.True(t, Remove(g))
+		assert.False(t, Remove(g))
+		Install(g, WithTracer(ft))
+		do(t, cl)
+		assert.Equal(t, 1, countExecutions(ft))
+		assert.True(t, RemoveTracer(g, ft))
+		assert.False(t, RemoveTracer(g, nil))
+	})
+	t.Run("Remove[Not installed]", func(t *testing.T) {
+		assert.False(t, Remove(&httpx.HandlerGroup{}))
+	})
+	t.Run("Remove[nil HandlerGroup]", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			Remove(nil)
+		})
+		assert.PanicsWithValue(t, nilHandlerGroupMsg, func() {
+			RemoveTracer(nil, nil)
+		})
+	})
+	t.Run("execution in progress", func(t *testing.T) {
+		ft1, ft2 := &fakeTracer{}, &fakeTracer{}
+		g := &httpx.HandlerGroup{}
+		p := Install(g, WithTracer(ft1))
+		e := newExecutionWithContext(t, rootCtx())
+
+		p.Handle(httpx.BeforeExecutionStart, e)
+		Install(g, WithTracer(ft2))
+		RemoveTracer(g, ft2)
+		p.Handle(httpx.AfterExecutionEnd, e)
+
+		exec := ft1.find("foo.com")
+		require.NotNil(t, exec)
+		assert.True(t, exec.closed)
+		assert.Empty(t, ft2.spans)
+	})
+	t.Run("execution started while removed", func(t *testing.T) {
+		ft := &fakeTracer{}
+		g := &httpx.HandlerGroup{}
+		p := Install(g, WithTracer(ft))
+		RemoveTracer(g, ft)
+		e := newExecutionWithContext(t, rootCtx())
+
+		p.Handle(httpx.BeforeExecutionStart, e)
+		Install(g, WithTracer(ft))
+		p.Handle(httpx.AfterExecutionEnd, e)
+
+		assert.Empty(t, ft.spans)
+	})
+}
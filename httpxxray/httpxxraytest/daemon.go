@@ -0,0 +1,315 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+)
+
+// DefaultWait is the time for which Daemon.Do waits for its root segment
+// to arrive.
+const DefaultWait = 5 * time.Second
+
+// maxDatagramSize is the largest UDP payload the X-Ray daemon accepts.
+const maxDatagramSize = 64 * 1024
+
+// A Daemon is a fake X-Ray daemon for end-to-end tests. It listens on a
+// local UDP port, parses the segment documents sent to it using the
+// X-Ray daemon wire format, and reassembles subsegments which the X-Ray
+// SDK streamed separately into the segment trees they belong to.
+//
+// Unlike a Recorder, which captures segment documents in memory, a
+// Daemon receives them over the network exactly as the real X-Ray
+// daemon would, so it also exercises the X-Ray SDK's emitter and its
+// streaming of large segment trees. Since the network is the loopback
+// interface, no external network access is needed.
+//
+// Segments are sent to the Daemon if they are begun from a context
+// returned by its Context method, or by its BeginSegment or Do methods.
+// Alternatively, pass the Daemon's address to xray.Configure as the
+// DaemonAddr to send all segments to it.
+type Daemon struct {
+	conn    *net.UDPConn
+	emitter *xray.DefaultEmitter
+
+	lock     sync.Mutex
+	docs     []*Segment
+	errs     []error
+	received chan struct{}
+	done     chan struct{}
+}
+
+// NewDaemon starts and returns a new Daemon. It panics if the daemon
+// cannot listen on a local UDP port.
+//
+// The X-Ray SDK emitter which sends segments to the daemon keeps a UDP
+// socket open for the life of the program, so tests should share one
+// Daemon rather than start one for each test.
+func NewDaemon() *Daemon {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to listen on a port: %v", err))
+	}
+
+	// The SDK's emitter opens a socket on its first emit, and never
+	// closes it, so a single emitter is shared by all the segments sent
+	// to the daemon.
+	emitter, err := xray.NewDefaultEmitter(conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		_ = conn.Close()
+		panic(fmt.Sprintf("httpxxraytest: failed to create emitter: %v", err))
+	}
+
+	d := &Daemon{
+		conn:     conn,
+		emitter:  emitter,
+		received: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.serve()
+	return d
+}
+
+// Addr returns the address of the daemon in the form "127.0.0.1:port",
+// as used by xray.Config.DaemonAddr.
+func (d *Daemon) Addr() string {
+	return d.conn.LocalAddr().String()
+}
+
+// Close stops the daemon. The segments received so far remain
+// available.
+func (d *Daemon) Close() {
+	_ = d.conn.Close()
+	<-d.done
+}
+
+// Context returns a copy of parent configured so that segments begun
+// from it are sampled and sent to the daemon.
+func (d *Daemon) Context(parent context.Context) context.Context {
+	ctx, err := xray.ContextWithConfig(parent, xray.Config{
+		Emitter:          d.emitter,
+		SamplingStrategy: alwaysSample{},
+	})
+	if err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to configure context: %v", err))
+	}
+
+	return ctx
+}
+
+// BeginSegment begins a sampled root segment named name which is sent
+// to the daemon when it is closed.
+func (d *Daemon) BeginSegment(parent context.Context, name string) (context.Context, *xray.Segment) {
+	return xray.BeginSegment(d.Context(parent), name)
+}
+
+// Do executes plan using client within a new sampled root segment, and
+// returns the execution, the document of the execution subsegment, and
+// the error from client.Do.
+//
+// Do waits up to DefaultWait for the root segment to arrive. The
+// execution subsegment document is the first subsegment of the root
+// segment. It is nil if the root segment has no subsegments, or did not
+// arrive in time.
+func (d *Daemon) Do(client *httpx.Client, plan *request.Plan) (*request.Execution, *Segment, error) {
+	ctx, seg := d.BeginSegment(plan.Context(), "httpxxraytest")
+	e, err := client.Do(plan.WithContext(ctx))
+	seg.Close(nil)
+
+	if doc := d.WaitForSegment(seg.ID, DefaultWait); doc != nil && len(doc.Subsegments) > 0 {
+		return e, doc.Subsegments[0], err
+	}
+
+	return e, nil, err
+}
+
+// Traces returns the reassembled traces received so far, in the order
+// in which their first segment document arrived.
+func (d *Daemon) Traces() []*Trace {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return assemble(d.docs)
+}
+
+// Trace returns the reassembled trace with the given trace ID, or nil if
+// no segment document for the trace has arrived.
+func (d *Daemon) Trace(traceID string) *Trace {
+	for _, t := range d.Traces() {
+		if t.ID == traceID {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// WaitForSegment waits up to timeout for the segment with the given ID
+// to arrive, and returns it with its reassembled subsegments, or nil if
+// it does not arrive in time. The segment may be a root segment or a
+// subsegment streamed separately.
+func (d *Daemon) WaitForSegment(id string, timeout time.Duration) *Segment {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		d.lock.Lock()
+		received := d.received
+		traces := assemble(d.docs)
+		d.lock.Unlock()
+
+		for _, t := range traces {
+			for _, s := range t.Segments {
+				if s.ID == id {
+					return s
+				}
+			}
+		}
+
+		select {
+		case <-received:
+		case <-timer.C:
+			return nil
+		case <-d.done:
+			return nil
+		}
+	}
+}
+
+// Errors returns an error for each datagram received so far which was
+// not a valid segment document in the X-Ray daemon wire format.
+func (d *Daemon) Errors() []error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	errs := make([]error, len(d.errs))
+	copy(errs, d.errs)
+	return errs
+}
+
+// Reset discards the segment documents and errors received so far.
+func (d *Daemon) Reset() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.docs = nil
+	d.errs = nil
+}
+
+func (d *Daemon) serve() {
+	defer close(d.done)
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, _, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		doc, err := parseDatagram(buf[:n])
+		d.lock.Lock()
+		if err != nil {
+			d.errs = append(d.errs, err)
+		} else {
+			d.docs = append(d.docs, doc)
+		}
+		close(d.received)
+		d.received = make(chan struct{})
+		d.lock.Unlock()
+	}
+}
+
+var errMissingHeader = errors.New("httpxxraytest: missing header line")
+
+// parseDatagram parses a datagram in the X-Ray daemon wire format, which
+// is a JSON header line followed by a segment document.
+func parseDatagram(b []byte) (*Segment, error) {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return nil, errMissingHeader
+	}
+
+	var h struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(b[:i], &h); err != nil {
+		return nil, fmt.Errorf("httpxxraytest: invalid header line: %w", err)
+	}
+	if h.Format != "json" || h.Version != 1 {
+		return nil, fmt.Errorf("httpxxraytest: unsupported format %q version %d", h.Format, h.Version)
+	}
+
+	var doc Segment
+	if err := json.Unmarshal(b[i+1:], &doc); err != nil {
+		return nil, fmt.Errorf("httpxxraytest: invalid segment document: %w", err)
+	}
+	if doc.ID == "" || doc.TraceID == "" {
+		return nil, fmt.Errorf("httpxxraytest: segment document missing id or trace_id")
+	}
+
+	return &doc, nil
+}
+
+// A Trace is a reassembled X-Ray trace received by a Daemon.
+type Trace struct {
+	// ID is the trace ID.
+	ID string
+
+	// Segments contains the segments of the trace, in the order in
+	// which they arrived, with their subsegment trees reassembled.
+	// Subsegments streamed separately are added to the subsegments of
+	// their parent. A streamed subsegment whose parent has not arrived
+	// is included in Segments itself.
+	Segments []*Segment
+}
+
+// assemble groups docs into traces, and grafts the subsegments which
+// were streamed separately onto their parents. The documents in docs are
+// not modified.
+func assemble(docs []*Segment) []*Trace {
+	var traces []*Trace
+	byTraceID := make(map[string]*Trace)
+	byID := make(map[string]*Segment)
+	copies := make([]*Segment, len(docs))
+	for i := range docs {
+		copies[i] = deepCopy(docs[i], byID)
+		t := byTraceID[docs[i].TraceID]
+		if t == nil {
+			t = &Trace{ID: docs[i].TraceID}
+			byTraceID[t.ID] = t
+			traces = append(traces, t)
+		}
+	}
+
+	for i, doc := range docs {
+		if doc.Type == "subsegment" {
+			if parent := byID[doc.ParentID]; parent != nil {
+				parent.Subsegments = append(parent.Subsegments, copies[i])
+				continue
+			}
+		}
+		t := byTraceID[doc.TraceID]
+		t.Segments = append(t.Segments, copies[i])
+	}
+
+	return traces
+}
+
+func deepCopy(s *Segment, byID map[string]*Segment) *Segment {
+	c := *s
+	c.Subsegments = nil
+	for _, sub := range s.Subsegments {
+		c.Subsegments = append(c.Subsegments, deepCopy(sub, byID))
+	}
+	byID[c.ID] = &c
+	return &c
+}
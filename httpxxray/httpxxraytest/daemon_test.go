@@ -0,0 +1,158 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+	"github.com/gogama/httpx/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon(t *testing.T) {
+	t.Run("BeginSegment", func(t *testing.T) {
+		d := NewDaemon()
+		defer d.Close()
+
+		ctx, seg := d.BeginSegment(context.Background(), "root")
+		_, sub := xray.BeginSubsegment(ctx, "child")
+		sub.Close(nil)
+		seg.Close(nil)
+
+		doc := d.WaitForSegment(seg.ID, DefaultWait)
+		require.NotNil(t, doc)
+		assert.Equal(t, "root", doc.Name)
+		require.Len(t, doc.Subsegments, 1)
+		assert.Equal(t, sub.ID, doc.Subsegments[0].ID)
+		trace := d.Trace(seg.TraceID)
+		require.NotNil(t, trace)
+		assert.Len(t, trace.Segments, 1)
+		assert.Empty(t, d.Errors())
+	})
+	t.Run("Context", func(t *testing.T) {
+		d := NewDaemon()
+		defer d.Close()
+		emitter := func(ctx context.Context) xray.Emitter {
+			c, _ := ctx.Value(xray.RecorderContextKey{}).(*xray.Config)
+			require.NotNil(t, c)
+			return c.Emitter
+		}
+
+		a := emitter(d.Context(context.Background()))
+		b := emitter(d.Context(context.Background()))
+
+		assert.Same(t, a, b)
+	})
+	t.Run("streamed subsegments", func(t *testing.T) {
+		d := NewDaemon()
+		defer d.Close()
+
+		ctx, seg := d.BeginSegment(context.Background(), "root")
+		parentCtx, parent := xray.BeginSubsegment(ctx, "parent")
+		for i := 0; i < 30; i++ {
+			_, sub := xray.BeginSubsegment(parentCtx, fmt.Sprintf("child:%d", i))
+			sub.Close(nil)
+		}
+		parent.Close(nil)
+		seg.Close(nil)
+
+		doc := d.WaitForSegment(seg.ID, DefaultWait)
+		require.NotNil(t, doc)
+		require.Len(t, doc.Subsegments, 1)
+		assert.Len(t, doc.Subsegments[0].Subsegments, 30)
+		d.lock.Lock()
+		n := len(d.docs)
+		d.lock.Unlock()
+		assert.Greater(t, n, 1, "subsegments must have been streamed separately")
+		require.Len(t, d.Traces(), 1)
+		assert.Len(t, d.Traces()[0].Segments, 1)
+	})
+	t.Run("malformed datagrams", func(t *testing.T) {
+		d := NewDaemon()
+		defer d.Close()
+		conn, err := net.Dial("udp", d.Addr())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		for _, datagram := range []string{
+			`{"id":"1"}`,
+			"{\"format\": \"json\", \"version\": 2}\n{}",
+			"{\"format\": \"json\", \"version\": 1}\n{",
+			"{\"format\": \"json\", \"version\": 1}\n{\"name\":\"x\"}",
+		} {
+			_, err = conn.Write([]byte(datagram))
+			require.NoError(t, err)
+		}
+
+		assert.Eventually(t, func() bool {
+			return len(d.Errors()) == 4
+		}, DefaultWait, time.Millisecond)
+		assert.Empty(t, d.Traces())
+		d.Reset()
+		assert.Empty(t, d.Errors())
+	})
+	t.Run("Do", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+		d := NewDaemon()
+		defer d.Close()
+		cl := &httpx.Client{
+			HTTPDoer:    server.Client(),
+			RetryPolicy: retry.NewPolicy(retry.Times(1).And(retry.StatusCode(429)), retry.DefaultWaiter),
+		}
+		httpxxray.OnClient(cl, nil)
+		pl, err := request.NewPlan("GET", server.URL, nil)
+		require.NoError(t, err)
+
+		e, seg, err := d.Do(cl, pl)
+
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		AssertSegment(t, Want{
+			Error:    true,
+			Throttle: true,
+			Status:   429,
+			Subsegments: []Want{
+				{Name: "Attempt:0", Error: true, Throttle: true, Status: 429},
+				{Name: "Attempt:1", Error: true, Throttle: true, Status: 429},
+			},
+		}, seg)
+	})
+}
+
+func TestAssemble(t *testing.T) {
+	docs := []*Segment{
+		{TraceID: "t1", ID: "s3", ParentID: "s2", Type: "subsegment"},
+		{TraceID: "t1", ID: "s1", Subsegments: []*Segment{{ID: "s2"}}},
+		{TraceID: "t2", ID: "s4", ParentID: "missing", Type: "subsegment"},
+	}
+
+	traces := assemble(docs)
+
+	require.Len(t, traces, 2)
+	assert.Equal(t, "t1", traces[0].ID)
+	require.Len(t, traces[0].Segments, 1)
+	assert.Equal(t, "s1", traces[0].Segments[0].ID)
+	require.Len(t, traces[0].Segments[0].Subsegments, 1)
+	require.Len(t, traces[0].Segments[0].Subsegments[0].Subsegments, 1)
+	assert.Equal(t, "s3", traces[0].Segments[0].Subsegments[0].Subsegments[0].ID)
+	assert.Equal(t, "t2", traces[1].ID)
+	require.Len(t, traces[1].Segments, 1)
+	assert.Equal(t, "s4", traces[1].Segments[0].ID)
+	assert.Empty(t, docs[1].Subsegments[0].Subsegments, "input documents must not be modified")
+}
@@ -0,0 +1,44 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package httpxxraytest provides utilities for testing the X-Ray traces
+produced by the httpxxray plugin, without running the AWS X-Ray daemon.
+
+A Recorder is an X-Ray emitter which captures segment documents in
+memory. Use its Do method to send a request plan from within a sampled
+segment and get back the document of the execution subsegment:
+
+	rec := httpxxraytest.NewRecorder()
+
+	cl := &httpx.Client{}
+	httpxxray.OnClient(cl, nil)
+
+	pl := request.NewPlan("GET", "https://www.example.com/things/123", nil)
+	e, seg, err := rec.Do(cl, pl)
+
+Then use AssertSegment to check the shape of the trace, including
+subsegment names, fault, error and throttle flags, annotations and
+metadata:
+
+	httpxxraytest.AssertSegment(t, httpxxraytest.Want{
+		Name:     "www.example.com",
+		Metadata: map[string]map[string]interface{}{"httpx": {"attempts": 1}},
+		Subsegments: []httpxxraytest.Want{
+			{Name: "Attempt:0"},
+		},
+	}, seg)
+
+A Daemon is a fake X-Ray daemon listening on a local UDP port. It has
+the same Do method as a Recorder, but receives segment documents over
+the network exactly as the real X-Ray daemon would, so it tests the
+whole emission path of the AWS X-Ray SDK for Go, including streaming
+of large segment trees, without any external network access:
+
+	d := httpxxraytest.NewDaemon()
+	defer d.Close()
+
+	e, seg, err := d.Do(cl, pl)
+*/
+package httpxxraytest
@@ -0,0 +1,180 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/aws/aws-xray-sdk-go/v2/strategy/sampling"
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+)
+
+// A Recorder is an xray.Emitter which captures the documents of the
+// X-Ray segments it emits in memory, instead of sending them to the
+// X-Ray daemon.
+//
+// The recorder only captures segments begun from a context returned by
+// its Context method, or begun by its BeginSegment or Do methods. Such
+// segments are always sampled, and their subsegments are never streamed
+// separately, so each root segment is captured as a single document
+// containing its whole subsegment tree.
+//
+// The AWS X-Ray SDK for Go only assembles a segment document as part of
+// sending it to the daemon, so a Recorder lets the SDK send a copy of
+// each document to a loopback UDP socket, where it is discarded. The
+// socket is shared by all Recorders, so a Recorder holds no resources
+// of its own and need not be closed.
+type Recorder struct {
+	lock sync.Mutex
+	docs []*Segment
+}
+
+// NewRecorder returns a new Recorder. It panics if the loopback UDP
+// socket shared by all Recorders cannot be opened.
+func NewRecorder() *Recorder {
+	if _, err := sharedPacker(); err != nil {
+		panic(err.Error())
+	}
+
+	return &Recorder{}
+}
+
+// packer is the X-Ray SDK emitter which Recorders use to assemble
+// segment documents, together with the loopback UDP socket to which it
+// sends them. The SDK's emitter opens its own socket, which it never
+// closes, so a single emitter is shared by all Recorders rather than
+// opening a socket for each.
+var packer struct {
+	once    sync.Once
+	sink    *net.UDPConn
+	emitter *xray.DefaultEmitter
+	err     error
+}
+
+func sharedPacker() (*xray.DefaultEmitter, error) {
+	packer.once.Do(func() {
+		packer.sink, packer.err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if packer.err != nil {
+			packer.err = fmt.Errorf("httpxxraytest: failed to listen on a port: %v", packer.err)
+			return
+		}
+
+		packer.emitter, packer.err = xray.NewDefaultEmitter(packer.sink.LocalAddr().(*net.UDPAddr))
+		if packer.err != nil {
+			_ = packer.sink.Close()
+			packer.err = fmt.Errorf("httpxxraytest: failed to create emitter: %v", packer.err)
+		}
+	})
+	return packer.emitter, packer.err
+}
+
+// Emit captures the document of seg. It is called by the AWS X-Ray SDK
+// for Go when a segment begun by the recorder is complete.
+func (r *Recorder) Emit(seg *xray.Segment) {
+	if seg == nil || !seg.ParentSegment.Sampled {
+		return
+	}
+
+	// The caller holds the write lock on seg. Emitting it using the
+	// SDK's own emitter first assembles the documents of its
+	// subsegments into seg.Subsegments.
+	packer.emitter.Emit(seg)
+	b, err := json.Marshal(seg)
+	if err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to marshal segment: %v", err))
+	}
+
+	var doc Segment
+	if err = json.Unmarshal(b, &doc); err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to unmarshal segment: %v", err))
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.docs = append(r.docs, &doc)
+}
+
+// RefreshEmitterWithAddress does nothing, since a Recorder never sends
+// segments to the X-Ray daemon.
+func (r *Recorder) RefreshEmitterWithAddress(_ *net.UDPAddr) {
+}
+
+// Context returns a copy of parent configured so that segments begun
+// from it are sampled and emitted to the recorder.
+func (r *Recorder) Context(parent context.Context) context.Context {
+	streaming, err := xray.NewDefaultStreamingStrategyWithMaxSubsegmentCount(math.MaxInt32)
+	if err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to create streaming strategy: %v", err))
+	}
+
+	ctx, err := xray.ContextWithConfig(parent, xray.Config{
+		Emitter:           r,
+		SamplingStrategy:  alwaysSample{},
+		StreamingStrategy: streaming,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("httpxxraytest: failed to configure context: %v", err))
+	}
+
+	return ctx
+}
+
+// BeginSegment begins a sampled root segment named name which is
+// emitted to the recorder when it is closed.
+func (r *Recorder) BeginSegment(parent context.Context, name string) (context.Context, *xray.Segment) {
+	return xray.BeginSegment(r.Context(parent), name)
+}
+
+// Do executes plan using client within a new sampled root segment, and
+// returns the execution, the document of the execution subsegment, and
+// the error from client.Do.
+//
+// The execution subsegment document is the first subsegment of the root
+// segment. It is nil if the root segment has no subsegments, for example
+// because the plugin is not installed on client, or if the root segment
+// was not emitted because some of its subsegments are still open.
+func (r *Recorder) Do(client *httpx.Client, plan *request.Plan) (*request.Execution, *Segment, error) {
+	ctx, seg := r.BeginSegment(plan.Context(), "httpxxraytest")
+	e, err := client.Do(plan.WithContext(ctx))
+	seg.Close(nil)
+
+	for _, doc := range r.Documents() {
+		if doc.ID == seg.ID && len(doc.Subsegments) > 0 {
+			return e, doc.Subsegments[0], err
+		}
+	}
+
+	return e, nil, err
+}
+
+// Documents returns the documents captured so far, in the order in
+// which they were emitted.
+func (r *Recorder) Documents() []*Segment {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	docs := make([]*Segment, len(r.docs))
+	copy(docs, r.docs)
+	return docs
+}
+
+// Reset discards the documents captured so far.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.docs = nil
+}
+
+type alwaysSample struct{}
+
+func (alwaysSample) ShouldTrace(_ *sampling.Request) *sampling.Decision {
+	return &sampling.Decision{Sample: true}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/aws-xray-httpx/httpxxray/v2"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+	"github.com/gogama/httpx/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Run("BeginSegment", func(t *testing.T) {
+		rec := NewRecorder()
+
+		ctx, seg := rec.BeginSegment(context.Background(), "root")
+		_, sub := xray.BeginSubsegment(ctx, "child")
+		sub.Close(nil)
+		seg.Close(nil)
+
+		docs := rec.Documents()
+		require.Len(t, docs, 1)
+		assert.Equal(t, seg.ID, docs[0].ID)
+		assert.Equal(t, "root", docs[0].Name)
+		require.Len(t, docs[0].Subsegments, 1)
+		assert.Equal(t, "child", docs[0].Subsegments[0].Name)
+		assert.Equal(t, sub.ID, docs[0].Subsegments[0].ID)
+	})
+	t.Run("shared packer", func(t *testing.T) {
+		_, seg1 := NewRecorder().BeginSegment(context.Background(), "a")
+		seg1.Close(nil)
+		sink := packer.sink
+		_, seg2 := NewRecorder().BeginSegment(context.Background(), "b")
+		seg2.Close(nil)
+
+		assert.NotNil(t, sink)
+		assert.Same(t, sink, packer.sink)
+	})
+	t.Run("Reset", func(t *testing.T) {
+		rec := NewRecorder()
+		_, seg := rec.BeginSegment(context.Background(), "root")
+		seg.Close(nil)
+
+		rec.Reset()
+
+		assert.Empty(t, rec.Documents())
+	})
+	t.Run("Do", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.NotEmpty(t, r.Header.Get(xray.TraceIDHeaderKey))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		rec := NewRecorder()
+		cl := &httpx.Client{
+			HTTPDoer:    server.Client(),
+			RetryPolicy: retry.NewPolicy(retry.Times(1).And(retry.StatusCode(503)), retry.DefaultWaiter),
+		}
+		httpxxray.OnClientWithOptions(cl, httpxxray.WithSegmentNamer(httpxxray.HostnameNamer))
+		pl, err := request.NewPlan("GET", server.URL, nil)
+		require.NoError(t, err)
+
+		e, seg, err := rec.Do(cl, pl)
+
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		require.NotNil(t, seg)
+		AssertSegment(t, Want{
+			Name:      "127.0.0.1",
+			Namespace: "remote",
+			Fault:     true,
+			Status:    503,
+			Metadata:  map[string]map[string]interface{}{"httpx": {"attempts": 2}},
+			Subsegments: []Want{
+				{Name: "Attempt:0", Fault: true, Status: 503},
+				{Name: "Attempt:1", Fault: true, Status: 503},
+			},
+		}, seg)
+		attempts := seg.Attempts()
+		require.Len(t, attempts, 2)
+		assert.Equal(t, "Attempt:0", attempts[0].Name)
+		assert.Equal(t, "Attempt:1", attempts[1].Name)
+	})
+	t.Run("Do[NotInstalled]", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		rec := NewRecorder()
+		pl, err := request.NewPlan("GET", server.URL, nil)
+		require.NoError(t, err)
+
+		e, seg, err := rec.Do(&httpx.Client{HTTPDoer: server.Client()}, pl)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, e)
+		assert.Nil(t, seg)
+		assert.Len(t, rec.Documents(), 1)
+	})
+}
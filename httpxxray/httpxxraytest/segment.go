@@ -0,0 +1,242 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+)
+
+// A Segment is a decoded X-Ray segment or subsegment document.
+//
+// Since the document is decoded from JSON, numeric annotation and
+// metadata values are float64, and structured metadata values are
+// map[string]interface{} or []interface{}.
+type Segment struct {
+	TraceID    string  `json:"trace_id"`
+	ID         string  `json:"id"`
+	ParentID   string  `json:"parent_id"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Namespace  string  `json:"namespace"`
+	StartTime  float64 `json:"start_time"`
+	EndTime    float64 `json:"end_time"`
+	InProgress bool    `json:"in_progress"`
+
+	Fault    bool            `json:"fault"`
+	Error    bool            `json:"error"`
+	Throttle bool            `json:"throttle"`
+	Cause    *xray.CauseData `json:"cause"`
+
+	HTTP *xray.HTTPData         `json:"http"`
+	AWS  map[string]interface{} `json:"aws"`
+
+	Annotations map[string]interface{}            `json:"annotations"`
+	Metadata    map[string]map[string]interface{} `json:"metadata"`
+
+	Subsegments []*Segment `json:"subsegments"`
+}
+
+// Subsegment returns the first direct subsegment of s named name, or
+// nil if s has no such subsegment.
+func (s *Segment) Subsegment(name string) *Segment {
+	for _, sub := range s.Subsegments {
+		if sub.Name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// Attempts returns the attempt subsegments of an execution subsegment,
+// ordered by attempt number. The attempt subsegment of attempt n is
+// named "Attempt:n".
+func (s *Segment) Attempts() []*Segment {
+	var attempts []*Segment
+	for _, sub := range s.Subsegments {
+		if _, ok := attemptNumber(sub.Name); ok {
+			attempts = append(attempts, sub)
+		}
+	}
+
+	// Insertion sort, since there are very few attempts.
+	for i := 1; i < len(attempts); i++ {
+		for j := i; j > 0 && attemptLess(attempts[j], attempts[j-1]); j-- {
+			attempts[j], attempts[j-1] = attempts[j-1], attempts[j]
+		}
+	}
+
+	return attempts
+}
+
+func attemptNumber(name string) (int, bool) {
+	if !strings.HasPrefix(name, "Attempt:") {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(name[len("Attempt:"):])
+	return n, err == nil
+}
+
+func attemptLess(a, b *Segment) bool {
+	m, _ := attemptNumber(a.Name)
+	n, _ := attemptNumber(b.Name)
+	return m < n
+}
+
+// A Want describes the expected content of a segment document, for use
+// with AssertSegment.
+type Want struct {
+	// Name is the expected segment name. If empty, the name is not
+	// checked.
+	Name string
+
+	// Namespace is the expected segment namespace. If empty, the
+	// namespace is not checked.
+	Namespace string
+
+	// Fault, Error and Throttle are the expected values of the segment's
+	// flags. They are always checked.
+	Fault    bool
+	Error    bool
+	Throttle bool
+
+	// Status is the expected HTTP response status code. If zero, the
+	// status code is not checked.
+	Status int
+
+	// Annotations lists annotations which the segment must have. Other
+	// annotations are ignored. Values are compared after conversion to
+	// JSON, so an int annotation value may be given as an int.
+	Annotations map[string]interface{}
+
+	// Metadata lists metadata, by namespace and key, which the segment
+	// must have. Other metadata are ignored. Values are compared in the
+	// same way as annotation values.
+	Metadata map[string]map[string]interface{}
+
+	// Subsegments lists subsegments which the segment must have. Each
+	// wanted subsegment is matched, by name, to a different subsegment
+	// of the segment, in order. Other subsegments, such as those which
+	// the X-Ray SDK records for connection setup, are ignored.
+	Subsegments []Want
+}
+
+// TestingT is the subset of testing.TB used by AssertSegment.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// AssertSegment checks that got matches want and its wanted
+// subsegments, recursively. It reports each mismatch using t.Errorf and
+// returns true if there are none.
+func AssertSegment(t TestingT, want Want, got *Segment) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	if got == nil {
+		t.Errorf("httpxxraytest: missing segment %q", want.Name)
+		return false
+	}
+
+	var problems []string
+	compare(&problems, got.Name, want, got)
+	for _, p := range problems {
+		t.Errorf("httpxxraytest: %s", p)
+	}
+
+	return len(problems) == 0
+}
+
+func compare(problems *[]string, path string, want Want, got *Segment) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if want.Name != "" && want.Name != got.Name {
+		fail("name is %q, want %q", got.Name, want.Name)
+	}
+	if want.Namespace != "" && want.Namespace != got.Namespace {
+		fail("namespace is %q, want %q", got.Namespace, want.Namespace)
+	}
+	if want.Fault != got.Fault {
+		fail("fault is %t, want %t", got.Fault, want.Fault)
+	}
+	if want.Error != got.Error {
+		fail("error is %t, want %t", got.Error, want.Error)
+	}
+	if want.Throttle != got.Throttle {
+		fail("throttle is %t, want %t", got.Throttle, want.Throttle)
+	}
+	if want.Status != 0 {
+		status := 0
+		if got.HTTP != nil && got.HTTP.Response != nil {
+			status = got.HTTP.Response.Status
+		}
+		if want.Status != status {
+			fail("HTTP status is %d, want %d", status, want.Status)
+		}
+	}
+
+	for k, v := range want.Annotations {
+		compareValue(fail, "annotation "+k, k, v, got.Annotations)
+	}
+	for ns, kv := range want.Metadata {
+		for k, v := range kv {
+			compareValue(fail, "metadata "+ns+"."+k, k, v, got.Metadata[ns])
+		}
+	}
+
+	used := make([]bool, len(got.Subsegments))
+	for _, w := range want.Subsegments {
+		i := 0
+		for ; i < len(got.Subsegments); i++ {
+			if !used[i] && (w.Name == "" || w.Name == got.Subsegments[i].Name) {
+				break
+			}
+		}
+		if i == len(got.Subsegments) {
+			fail("missing subsegment %q", w.Name)
+			continue
+		}
+		used[i] = true
+		compare(problems, path+"/"+got.Subsegments[i].Name, w, got.Subsegments[i])
+	}
+}
+
+func compareValue(fail func(string, ...interface{}), what, key string, want interface{}, got map[string]interface{}) {
+	g, ok := got[key]
+	if !ok {
+		fail("missing %s", what)
+		return
+	}
+
+	if w := normalize(want); !reflect.DeepEqual(w, g) {
+		fail("%s is %#v, want %#v", what, g, w)
+	}
+}
+
+// normalize converts v into the value it would have after being
+// encoded as JSON and decoded again.
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var n interface{}
+	if err = json.Unmarshal(b, &n); err != nil {
+		return v
+	}
+
+	return n
+}
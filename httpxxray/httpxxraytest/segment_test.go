@@ -0,0 +1,126 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxraytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegment_Subsegment(t *testing.T) {
+	a := &Segment{Name: "a"}
+	s := &Segment{Subsegments: []*Segment{a, {Name: "b"}, {Name: "a"}}}
+
+	assert.Same(t, a, s.Subsegment("a"))
+	assert.Nil(t, s.Subsegment("c"))
+}
+
+func TestSegment_Attempts(t *testing.T) {
+	s := &Segment{Subsegments: []*Segment{
+		{Name: "Attempt:10"},
+		{Name: "Backoff"},
+		{Name: "Attempt:2"},
+		{Name: "Attempt:x"},
+		{Name: "Attempt:0"},
+	}}
+
+	attempts := s.Attempts()
+
+	names := make([]string, len(attempts))
+	for i := range attempts {
+		names[i] = attempts[i].Name
+	}
+	assert.Equal(t, []string{"Attempt:0", "Attempt:2", "Attempt:10"}, names)
+}
+
+func TestAssertSegment(t *testing.T) {
+	seg := &Segment{
+		Name:        "exec",
+		Namespace:   "remote",
+		Error:       true,
+		Throttle:    true,
+		HTTP:        &xray.HTTPData{Response: &xray.ResponseData{Status: 429}},
+		Annotations: map[string]interface{}{"n": float64(1), "s": "x"},
+		Metadata:    map[string]map[string]interface{}{"httpx": {"attempts": float64(2)}},
+		Subsegments: []*Segment{
+			{Name: "Attempt:0", Error: true},
+			{Name: "connect"},
+			{Name: "Attempt:1"},
+		},
+	}
+	testCases := []struct {
+		name     string
+		want     Want
+		problems []string
+	}{
+		{
+			name: "match",
+			want: Want{
+				Name:        "exec",
+				Namespace:   "remote",
+				Error:       true,
+				Throttle:    true,
+				Status:      429,
+				Annotations: map[string]interface{}{"n": 1},
+				Metadata:    map[string]map[string]interface{}{"httpx": {"attempts": 2}},
+				Subsegments: []Want{{Name: "Attempt:0", Error: true}, {Name: "Attempt:1"}},
+			},
+		},
+		{
+			name: "mismatch",
+			want: Want{
+				Name:        "other",
+				Namespace:   "aws",
+				Fault:       true,
+				Status:      200,
+				Annotations: map[string]interface{}{"n": 2, "m": true},
+				Metadata:    map[string]map[string]interface{}{"other": {"attempts": 2}},
+				Subsegments: []Want{{Name: "Attempt:0"}, {Name: "Attempt:0"}},
+			},
+			problems: []string{
+				`httpxxraytest: exec: name is "exec", want "other"`,
+				`httpxxraytest: exec: namespace is "remote", want "aws"`,
+				`httpxxraytest: exec: fault is false, want true`,
+				`httpxxraytest: exec: error is true, want false`,
+				`httpxxraytest: exec: throttle is true, want false`,
+				`httpxxraytest: exec: HTTP status is 429, want 200`,
+				`httpxxraytest: exec: annotation n is 1, want 2`,
+				`httpxxraytest: exec: missing annotation m`,
+				`httpxxraytest: exec: missing metadata other.attempts`,
+				`httpxxraytest: exec/Attempt:0: error is true, want false`,
+				`httpxxraytest: exec: missing subsegment "Attempt:0"`,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := &recordingT{}
+
+			ok := AssertSegment(r, testCase.want, seg)
+
+			assert.Equal(t, len(testCase.problems) == 0, ok)
+			assert.ElementsMatch(t, testCase.problems, r.problems)
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		r := &recordingT{}
+
+		ok := AssertSegment(r, Want{Name: "exec"}, nil)
+
+		assert.False(t, ok)
+		assert.Equal(t, []string{`httpxxraytest: missing segment "exec"`}, r.problems)
+	})
+}
+
+type recordingT struct {
+	problems []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.problems = append(r.problems, fmt.Sprintf(format, args...))
+}
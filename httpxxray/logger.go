@@ -4,6 +4,11 @@
 
 package httpxxray
 
+import (
+	"context"
+	"fmt"
+)
+
 // Logger allows the X-Ray plugin to log issues it has encountered. The
 // interface is compatible with the Go standard log.Logger.
 //
@@ -20,5 +25,73 @@ type Logger interface {
 // issues encountered by the X-Ray plugin.
 type NopLogger struct{}
 
-func (_ NopLogger) Printf(string, ...interface{}) {
+func (NopLogger) Printf(string, ...interface{}) {
+}
+
+// Log ignores the message, so that NopLogger also implements
+// StructuredLogger.
+func (NopLogger) Log(context.Context, Level, string, ...Field) {
+}
+
+// A StructuredLogger is a Logger which can also log leveled messages
+// with structured fields. If the configured Logger implements
+// StructuredLogger, the X-Ray plugin uses Log instead of Printf, so that
+// log pipelines can index the plugin's diagnostics without parsing
+// them. Use NewSlogLogger to adapt a log/slog Logger.
+//
+// Implementations of StructuredLogger must be safe for concurrent use by
+// multiple goroutines.
+type StructuredLogger interface {
+	Logger
+
+	// Log logs a message at the given level. The context is the
+	// context of the request plan or request attempt concerned, and
+	// fields gives further details such as the httpx event name, the
+	// destination host, and the attempt number.
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// A Level is the importance of a log message. The levels have the same
+// values as the corresponding log/slog levels.
+type Level int
+
+const (
+	// LevelDebug is the level of diagnostic messages.
+	LevelDebug Level = -4
+	// LevelInfo is the level of informational messages.
+	LevelInfo Level = 0
+	// LevelWarn is the level of messages about issues which prevent
+	// the plugin from tracing part of a request plan execution.
+	LevelWarn Level = 4
+	// LevelError is the level of messages about issues which prevent
+	// the plugin from working at all.
+	LevelError Level = 8
+)
+
+// String returns the name of the level, for example "WARN". Levels
+// between the named levels are named after the level below, with an
+// offset, for example "WARN+2", as in log/slog.
+func (l Level) String() string {
+	base, offset := "DEBUG", l-LevelDebug
+	switch {
+	case l >= LevelError:
+		base, offset = "ERROR", l-LevelError
+	case l >= LevelWarn:
+		base, offset = "WARN", l-LevelWarn
+	case l >= LevelInfo:
+		base, offset = "INFO", l-LevelInfo
+	}
+
+	if offset == 0 {
+		return base
+	}
+
+	return fmt.Sprintf("%s%+d", base, offset)
+}
+
+// A Field is a key/value pair giving structured detail about a log
+// message.
+type Field struct {
+	Key   string
+	Value interface{}
 }
@@ -5,8 +5,10 @@
 package httpxxray
 
 import (
+	"context"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -16,6 +18,32 @@ func TestNopLogger_Printf(t *testing.T) {
 	l.Printf("bar['%s']='%v'", "baz", "qux")
 }
 
+func TestNopLogger_Log(t *testing.T) {
+	var l StructuredLogger = NopLogger{}
+	l.Log(context.Background(), LevelWarn, "foo", Field{"bar", 1})
+}
+
+func TestLevel_String(t *testing.T) {
+	testCases := []struct {
+		level Level
+		name  string
+	}{
+		{LevelDebug - 1, "DEBUG-1"},
+		{LevelDebug, "DEBUG"},
+		{LevelDebug + 1, "DEBUG+1"},
+		{LevelInfo, "INFO"},
+		{LevelWarn, "WARN"},
+		{LevelWarn + 2, "WARN+2"},
+		{LevelError, "ERROR"},
+		{LevelError + 4, "ERROR+4"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.name, testCase.level.String())
+		})
+	}
+}
+
 type mockLogger struct {
 	mock.Mock
 }
@@ -29,3 +57,17 @@ func newMockLogger(t *testing.T) *mockLogger {
 func (m *mockLogger) Printf(f string, a ...interface{}) {
 	m.Called(f, a)
 }
+
+type mockStructuredLogger struct {
+	mockLogger
+}
+
+func newMockStructuredLogger(t *testing.T) *mockStructuredLogger {
+	m := &mockStructuredLogger{}
+	m.Test(t)
+	return m
+}
+
+func (m *mockStructuredLogger) Log(_ context.Context, level Level, msg string, fields ...Field) {
+	m.Called(level, msg, fields)
+}
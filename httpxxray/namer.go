@@ -0,0 +1,176 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/gogama/httpx/request"
+)
+
+// A SegmentNamer decides the name of the X-Ray subsegment which
+// represents an entire request plan execution. Because X-Ray uses the
+// subsegment name to identify the downstream node on the service map,
+// choosing a stable, logical name keeps the service map readable.
+//
+// Implementations of SegmentNamer must be safe for concurrent use by
+// multiple goroutines.
+type SegmentNamer interface {
+	// Name returns the subsegment name for the plan. If the return
+	// value is the empty string, the plugin falls back to HostNamer.
+	Name(p *request.Plan) string
+}
+
+// The SegmentNamerFunc type is an adapter to allow the use of ordinary
+// functions as segment namers. If f is a function with appropriate
+// signature, then SegmentNamerFunc(f) is a SegmentNamer that calls f.
+type SegmentNamerFunc func(p *request.Plan) string
+
+// Name calls f(p).
+func (f SegmentNamerFunc) Name(p *request.Plan) string {
+	return f(p)
+}
+
+var (
+	// HostNamer names the execution subsegment after the plan's host,
+	// including the port if there is one. If the plan's Host field is
+	// set, it takes precedence over the URL host. HostNamer is the
+	// default SegmentNamer.
+	HostNamer SegmentNamer = SegmentNamerFunc(host)
+
+	// HostnameNamer names the execution subsegment after the plan's
+	// host, with any port removed. Thus requests to "example.com",
+	// "example.com:443" and "example.com:8443" all share the same
+	// name.
+	HostnameNamer SegmentNamer = SegmentNamerFunc(hostname)
+)
+
+// NewStaticNamer returns a SegmentNamer which maps hosts to logical
+// service names using a lookup table. The table is copied, so later
+// changes to it have no effect on the returned namer.
+//
+// Each plan's host is first looked up in the table exactly as returned
+// by HostNamer (i.e. including the port if present), and if it is not
+// found, it is looked up again with the port removed. If neither
+// lookup finds an entry, the name given by HostnameNamer is used.
+func NewStaticNamer(table map[string]string) SegmentNamer {
+	t := make(map[string]string, len(table))
+	for k, v := range table {
+		t[strings.ToLower(k)] = v
+	}
+	return SegmentNamerFunc(func(p *request.Plan) string {
+		h := strings.ToLower(host(p))
+		if name, ok := t[h]; ok {
+			return name
+		}
+		hn := hostnameOf(h)
+		if name, ok := t[hn]; ok {
+			return name
+		}
+		return hn
+	})
+}
+
+// NewRouteNamer returns a SegmentNamer which names the execution
+// subsegment using the plan's HTTP method and the first route template
+// which matches the URL path, for example "GET /things/:id".
+//
+// A route template is a slash-separated path in which any segment
+// beginning with a colon (':') matches exactly one arbitrary path
+// segment, and a final segment consisting solely of an asterisk ('*')
+// matches the remainder of the path. All other segments must match
+// exactly. For example the template "/users/:id/orders/*" matches the
+// path "/users/123/orders/456/items".
+//
+// If no template matches the plan's URL path, the name given by
+// HostnameNamer is used, so that arbitrary paths (which may contain
+// identifiers) never leak into the subsegment name.
+//
+// Because X-Ray restricts the characters allowed in a segment name,
+// the template is adjusted before it is used in the name: a final
+// asterisk segment is written as "...", and any other character X-Ray
+// does not allow is replaced with an underscore. Thus the template
+// "/users/:id/orders/*" produces the name "GET /users/:id/orders/...".
+func NewRouteNamer(templates ...string) SegmentNamer {
+	routes := make([][]string, len(templates))
+	names := make([]string, len(templates))
+	for i := range templates {
+		routes[i] = splitPath(templates[i])
+		names[i] = routeName(templates[i])
+	}
+	return SegmentNamerFunc(func(p *request.Plan) string {
+		if p.URL != nil {
+			path := splitPath(p.URL.Path)
+			for i := range routes {
+				if matchRoute(routes[i], path) {
+					return method(p) + " " + names[i]
+				}
+			}
+		}
+		return hostname(p)
+	})
+}
+
+func hostname(p *request.Plan) string {
+	return hostnameOf(host(p))
+}
+
+func hostnameOf(h string) string {
+	if hn, _, err := net.SplitHostPort(h); err == nil {
+		return hn
+	}
+	return h
+}
+
+func method(p *request.Plan) string {
+	if p.Method == "" {
+		return "GET"
+	}
+	return p.Method
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func routeName(template string) string {
+	if strings.HasSuffix(template, "/*") || template == "*" {
+		template = template[:len(template)-1] + "..."
+	}
+	return segmentName(template)
+}
+
+// segmentName replaces each character of name which X-Ray does not allow
+// in a segment name with an underscore.
+func segmentName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) ||
+			strings.ContainsRune(segmentNameSymbols, r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// segmentNameSymbols lists the symbols, other than letters, numbers and
+// whitespace, which X-Ray allows in a segment name.
+const segmentNameSymbols = `_.:/%&#=+\-@`
+
+func matchRoute(route, path []string) bool {
+	for i, seg := range route {
+		if seg == "*" && i == len(route)-1 {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(seg, ":") && seg != path[i] {
+			return false
+		}
+	}
+	return len(route) == len(path)
+}
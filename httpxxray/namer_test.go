@@ -0,0 +1,107 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"testing"
+
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentNamerFunc(t *testing.T) {
+	var q *request.Plan
+	f := SegmentNamerFunc(func(p *request.Plan) string {
+		q = p
+		return "foo"
+	})
+	p := newPlan(t, "", "http://bar.com")
+
+	assert.Equal(t, "foo", f.Name(p))
+	assert.Same(t, p, q)
+}
+
+func TestHostNamer(t *testing.T) {
+	assert.Equal(t, "foo.com", HostNamer.Name(newPlan(t, "", "http://foo.com")))
+	assert.Equal(t, "foo.com:8080", HostNamer.Name(newPlan(t, "", "http://foo.com:8080/bar")))
+	assert.Equal(t, "127.0.0.1:80", HostNamer.Name(newPlan(t, "", "http://127.0.0.1:80")))
+}
+
+func TestHostnameNamer(t *testing.T) {
+	assert.Equal(t, "foo.com", HostnameNamer.Name(newPlan(t, "", "http://foo.com")))
+	assert.Equal(t, "foo.com", HostnameNamer.Name(newPlan(t, "", "http://foo.com:8080/bar")))
+	assert.Equal(t, "127.0.0.1", HostnameNamer.Name(newPlan(t, "", "http://127.0.0.1:80")))
+	assert.Equal(t, "::1", HostnameNamer.Name(newPlan(t, "", "http://[::1]:443")))
+}
+
+func TestNewStaticNamer(t *testing.T) {
+	table := map[string]string{
+		"foo.com":          "Foo",
+		"bar.com:8080":     "Bar8080",
+		"bar.com":          "Bar",
+		"Mixed.Case.Com":   "Mixed",
+		"10.0.0.1:9000":    "Internal",
+		"unused.host:1234": "Unused",
+	}
+	n := NewStaticNamer(table)
+	table["baz.com"] = "Baz"
+
+	testCases := []struct {
+		url  string
+		name string
+	}{
+		{"http://foo.com", "Foo"},
+		{"http://foo.com:8443", "Foo"},
+		{"http://bar.com:8080", "Bar8080"},
+		{"http://bar.com:9090", "Bar"},
+		{"http://mixed.case.com", "Mixed"},
+		{"http://10.0.0.1:9000", "Internal"},
+		{"http://10.0.0.1:9001", "10.0.0.1"},
+		{"http://baz.com:80", "baz.com"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			assert.Equal(t, testCase.name, n.Name(newPlan(t, "", testCase.url)))
+		})
+	}
+}
+
+func TestNewRouteNamer(t *testing.T) {
+	n := NewRouteNamer("/things/:id", "/users/:id/orders/*", "/", "/static/path", "/odd/{name}/!")
+
+	testCases := []struct {
+		method string
+		url    string
+		name   string
+	}{
+		{"", "http://foo.com/things/123", "GET /things/:id"},
+		{"PUT", "http://foo.com/things/abc/", "PUT /things/:id"},
+		{"POST", "http://foo.com/users/1/orders/2/items", "POST /users/:id/orders/..."},
+		{"GET", "http://foo.com/users/1/orders", "GET /users/:id/orders/..."},
+		{"GET", "http://foo.com", "GET /"},
+		{"GET", "http://foo.com/", "GET /"},
+		{"DELETE", "http://foo.com/static/path", "DELETE /static/path"},
+		{"GET", "http://foo.com/odd/%7Bname%7D/%21", "GET /odd/_name_/_"},
+		{"GET", "http://foo.com:8080/things", "foo.com"},
+		{"GET", "http://foo.com:8080/things/123/extra", "foo.com"},
+		{"GET", "http://foo.com/static/other", "foo.com"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.method+" "+testCase.url, func(t *testing.T) {
+			assert.Equal(t, testCase.name, n.Name(newPlan(t, testCase.method, testCase.url)))
+		})
+	}
+	t.Run("nil URL", func(t *testing.T) {
+		p := &request.Plan{Host: "foo.com:80"}
+		assert.Equal(t, "foo.com", n.Name(p))
+	})
+}
+
+func newPlan(t *testing.T, method, url string) *request.Plan {
+	p, err := request.NewPlan(method, url, nil)
+	require.NoError(t, err)
+	return p
+}
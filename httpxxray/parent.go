@@ -0,0 +1,102 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/httpx/request"
+)
+
+const (
+	emptyNameMsg  = "httpxxray: empty segment name"
+	nilSegmentMsg = "httpxxray: nil segment"
+)
+
+// A ParentResolver supplies the parent of the execution subsegment when
+// the request plan's context does not contain an X-Ray segment, as is
+// often the case in background workers and command line tools, which
+// do not serve traced requests. Without a ParentResolver, such a
+// request plan execution is not traced.
+//
+// The plugin only consults the ParentResolver when the Tracer cannot
+// begin the execution span from the plan context. The default Tracer
+// never calls the X-Ray SDK when the plan context has no parent, so the
+// SDK's context missing strategy, which may panic, is never triggered
+// by the plugin.
+//
+// Implementations of ParentResolver must be safe for concurrent use by
+// multiple goroutines.
+type ParentResolver interface {
+	// Resolve returns a context, derived from the plan context ctx,
+	// which contains the parent for the execution of plan p. If the
+	// returned context is nil, the execution is not traced.
+	//
+	// If release is not nil, the plugin calls it once the execution
+	// subsegment has been closed, for example to close a segment begun
+	// by Resolve.
+	Resolve(ctx context.Context, p *request.Plan) (parent context.Context, release func())
+}
+
+// The ParentResolverFunc type is an adapter to allow the use of
+// ordinary functions as parent resolvers. If f is a function with
+// appropriate signature, then ParentResolverFunc(f) is a ParentResolver
+// that calls f.
+type ParentResolverFunc func(ctx context.Context, p *request.Plan) (context.Context, func())
+
+// Resolve calls f(ctx, p).
+func (f ParentResolverFunc) Resolve(ctx context.Context, p *request.Plan) (context.Context, func()) {
+	return f(ctx, p)
+}
+
+// NewRootSegmentResolver returns a ParentResolver which begins a new
+// X-Ray segment, named after the calling service, for each request plan
+// execution which has no parent, and closes it when the execution ends.
+// Each such execution is therefore recorded as a trace of its own. The
+// X-Ray SDK's sampling strategy decides whether each new segment is
+// sampled, and the AWS_XRAY_TRACING_NAME environment variable, if set,
+// overrides name.
+//
+// The function panics if name is empty.
+func NewRootSegmentResolver(name string) ParentResolver {
+	if name == "" {
+		panic(emptyNameMsg)
+	}
+
+	return ParentResolverFunc(func(ctx context.Context, _ *request.Plan) (context.Context, func()) {
+		ctx, seg := xray.BeginSegment(ctx, name)
+		return ctx, func() {
+			seg.Close(nil)
+		}
+	})
+}
+
+// NewProcessSegmentResolver returns a ParentResolver which attaches each
+// request plan execution which has no parent to seg, typically a
+// long-lived segment begun when the process starts and closed when it
+// exits:
+//
+//	ctx, seg := xray.BeginSegment(context.Background(), "worker")
+//	defer seg.Close(nil)
+//	httpxxray.OnClientWithOptions(cl, httpxxray.WithParentResolver(
+//		httpxxray.NewProcessSegmentResolver(seg),
+//	))
+//
+// Since X-Ray only emits a segment when it is closed, the execution
+// subsegments are only sent to X-Ray early if the X-Ray SDK's streaming
+// strategy streams them, which the default strategy does once the
+// segment has more than 20 subsegments.
+//
+// The function panics if seg is nil.
+func NewProcessSegmentResolver(seg *xray.Segment) ParentResolver {
+	if seg == nil {
+		panic(nilSegmentMsg)
+	}
+
+	return ParentResolverFunc(func(ctx context.Context, _ *request.Plan) (context.Context, func()) {
+		return context.WithValue(ctx, xray.ContextKey, seg), nil
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/httpx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRootSegmentResolver(t *testing.T) {
+	t.Run("empty name", func(t *testing.T) {
+		assert.PanicsWithValue(t, emptyNameMsg, func() {
+			NewRootSegmentResolver("")
+		})
+	})
+	t.Run("normal", func(t *testing.T) {
+		r := NewRootSegmentResolver("worker")
+		plan := newPlan(t, "", "http://foo.com")
+
+		ctx, release := r.Resolve(plan.Context(), plan)
+
+		seg := xray.GetSegment(ctx)
+		require.NotNil(t, seg)
+		assert.Equal(t, "worker", seg.Name)
+		assert.Same(t, seg, seg.ParentSegment)
+		assert.True(t, seg.InProgress)
+		require.NotNil(t, release)
+		release()
+		assert.False(t, seg.InProgress)
+	})
+}
+
+func TestNewProcessSegmentResolver(t *testing.T) {
+	t.Run("nil segment", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilSegmentMsg, func() {
+			NewProcessSegmentResolver(nil)
+		})
+	})
+	t.Run("normal", func(t *testing.T) {
+		_, seg := xray.BeginSegment(context.Background(), "process")
+		defer seg.Close(nil)
+		r := NewProcessSegmentResolver(seg)
+		plan := newPlan(t, "", "http://foo.com")
+
+		ctx, release := r.Resolve(plan.Context(), plan)
+
+		assert.Same(t, seg, xray.GetSegment(ctx))
+		assert.Nil(t, release)
+	})
+}
+
+func TestParentResolver_Integration(t *testing.T) {
+	cl := &httpx.Client{HTTPDoer: httpServer.Client()}
+	p := InstallOnClient(cl, WithParentResolver(NewRootSegmentResolver("worker")))
+	inst := serverInstruction{StatusCode: 200}
+
+	e, err := cl.Do(inst.toPlan(context.Background(), "", httpServer))
+
+	require.NoError(t, err)
+	execSeg := xray.GetSegment(e.Plan.Context())
+	require.NotNil(t, execSeg)
+	assert.False(t, execSeg.InProgress)
+	root := execSeg.ParentSegment
+	assert.Equal(t, "worker", root.Name)
+	assert.False(t, root.InProgress)
+	assert.Equal(t, uint64(1), p.Stats().ExecutionsTraced)
+}
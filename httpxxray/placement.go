@@ -0,0 +1,141 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"reflect"
+
+	"github.com/gogama/httpx"
+)
+
+const (
+	nilHandlerMsg      = "httpxxray: nil handler"
+	noEventsMsg        = "httpxxray: no events"
+	nonEmptyHandlerMsg = "httpxxray: handler group not empty"
+)
+
+// A Placement decides where the plugin's event handlers are placed
+// within an httpx HandlerGroup, relative to the other handlers in it.
+// The handlers in a handler group run in the order they were added, and
+// an httpx HandlerGroup does not support inserting or reordering
+// handlers, so a Placement can only arrange the plugin's handlers as they
+// are added.
+//
+// Where the plugin runs relative to another handler matters as follows:
+//
+// In the BeforeExecutionStart and BeforeAttempt events, the plugin
+// begins the execution and attempt subsegments, and in BeforeAttempt it
+// also injects the trace header into the request. A handler which runs
+// after the plugin is timed by the subsegment and sees the trace header,
+// so for example a request signing handler must run after the plugin to
+// sign the trace header. A handler which runs before the plugin is not
+// timed and does not see the trace header, but any change it makes to
+// the request, such as rewriting the URL, is recorded.
+//
+// In the AfterAttemptTimeout, AfterAttempt, AfterPlanTimeout and
+// AfterExecutionEnd events, the plugin closes the attempt and execution
+// subsegments. A handler which runs before the plugin is timed by the
+// subsegment, and any change it makes to the execution, such as
+// replacing the error, is recorded. A handler which runs after the
+// plugin is not timed, and its changes are not recorded.
+//
+// In the BeforeReadBody event, the plugin begins the ReadBody
+// subsegment, so a handler which runs after the plugin is timed as part
+// of reading the response body.
+//
+// The zero value of Placement is Back.
+type Placement struct {
+	front   bool
+	handler httpx.Handler
+	events  []httpx.Event
+	after   bool
+}
+
+var (
+	// Back is a Placement which adds the plugin's handlers after the
+	// handlers already in the handler group, and before any added
+	// later. Back is the default Placement.
+	Back = Placement{}
+
+	// Front is a Placement which guarantees the plugin's handlers run
+	// before every other handler in the handler group, by requiring the
+	// plugin to be the first handler added to it. Installing the plugin
+	// with Front onto a handler group which already has handlers
+	// panics.
+	Front = Placement{front: true}
+)
+
+// Before returns a Placement which adds h to the handler group together
+// with the plugin, so that on each of the given events, the plugin runs
+// immediately before h. Since the plugin adds h, h must not also be
+// added to the handler group separately. For example, to sign requests
+// including their trace header:
+//
+//	httpxxray.WithPlacement(httpxxray.Before(signer, httpx.BeforeAttempt))
+//
+// The function panics if h is nil or no events are given.
+func Before(h httpx.Handler, evts ...httpx.Event) Placement {
+	return newPlacement(h, evts, false)
+}
+
+// After returns a Placement which adds h to the handler group together
+// with the plugin, so that on each of the given events, the plugin runs
+// immediately after h. Since the plugin adds h, h must not also be
+// added to the handler group separately.
+//
+// The function panics if h is nil or no events are given.
+func After(h httpx.Handler, evts ...httpx.Event) Placement {
+	return newPlacement(h, evts, true)
+}
+
+func newPlacement(h httpx.Handler, evts []httpx.Event, after bool) Placement {
+	if h == nil {
+		panic(nilHandlerMsg)
+	}
+	if len(evts) == 0 {
+		panic(noEventsMsg)
+	}
+
+	return Placement{
+		handler: h,
+		events:  append([]httpx.Event(nil), evts...),
+		after:   after,
+	}
+}
+
+// pluginEvents are the events which the plugin handles.
+var pluginEvents = [numEvents]bool{
+	httpx.BeforeExecutionStart: true,
+	httpx.BeforeAttempt:        true,
+	httpx.BeforeReadBody:       true,
+	httpx.AfterAttemptTimeout:  true,
+	httpx.AfterAttempt:         true,
+	httpx.AfterPlanTimeout:     true,
+	httpx.AfterExecutionEnd:    true,
+}
+
+// place adds p, and the placement's handler if any, to handlers.
+func (pl Placement) place(handlers *httpx.HandlerGroup, p httpx.Handler) {
+	if pl.front && !reflect.DeepEqual(*handlers, httpx.HandlerGroup{}) {
+		panic(nonEmptyHandlerMsg)
+	}
+
+	var other [numEvents]bool
+	for _, evt := range pl.events {
+		other[evt] = true
+	}
+	for i := 0; i < numEvents; i++ {
+		evt := httpx.Event(i)
+		if other[i] && pl.after {
+			handlers.PushBack(evt, pl.handler)
+		}
+		if pluginEvents[i] {
+			handlers.PushBack(evt, p)
+		}
+		if other[i] && !pl.after {
+			handlers.PushBack(evt, pl.handler)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacement(t *testing.T) {
+	t.Run("Before[nil handler]", func(t *testing.T) {
+		assert.PanicsWithValue(t, nilHandlerMsg, func() {
+			Before(nil, httpx.BeforeAttempt)
+		})
+	})
+	t.Run("After[no events]", func(t *testing.T) {
+		assert.PanicsWithValue(t, noEventsMsg, func() {
+			After(httpx.HandlerFunc(func(httpx.Event, *request.Execution) {}))
+		})
+	})
+	t.Run("Front[not empty]", func(t *testing.T) {
+		handlers := &httpx.HandlerGroup{}
+		handlers.PushBack(httpx.AfterAttempt, httpx.HandlerFunc(func(httpx.Event, *request.Execution) {}))
+
+		assert.PanicsWithValue(t, nonEmptyHandlerMsg, func() {
+			Install(handlers, WithPlacement(Front))
+		})
+	})
+
+	// Each case records, on every event, whether the attempt subsegment
+	// had begun when the other handler ran.
+	testCases := []struct {
+		name      string
+		placement func(h httpx.Handler) Placement
+		pushFirst bool
+		pushLast  bool
+		began     bool
+	}{
+		{
+			name:      "Back",
+			placement: func(httpx.Handler) Placement { return Back },
+			pushFirst: true,
+		},
+		{
+			name:      "Front",
+			placement: func(httpx.Handler) Placement { return Front },
+			pushLast:  true,
+			began:     true,
+		},
+		{
+			name: "Before",
+			placement: func(h httpx.Handler) Placement {
+				return Before(h, httpx.BeforeAttempt)
+			},
+			began: true,
+		},
+		{
+			name: "After",
+			placement: func(h httpx.Handler) Placement {
+				return After(h, httpx.BeforeAttempt)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var traceIDs []string
+			other := httpx.HandlerFunc(func(_ httpx.Event, e *request.Execution) {
+				traceIDs = append(traceIDs, e.Request.Header.Get(xray.TraceIDHeaderKey))
+			})
+			cl := &httpx.Client{HTTPDoer: httpServer.Client(), Handlers: &httpx.HandlerGroup{}}
+			if testCase.pushFirst {
+				cl.Handlers.PushBack(httpx.BeforeAttempt, other)
+			}
+			ft := &fakeTracer{}
+			p := InstallOnClient(cl, WithTracer(ft), WithPlacement(testCase.placement(other)))
+			Install(cl.Handlers, WithTracer(ft), WithPlacement(testCase.placement(other)))
+			if testCase.pushLast {
+				cl.Handlers.PushBack(httpx.BeforeAttempt, other)
+			}
+
+			ctx := context.WithValue(context.Background(), fakeSpanKey, &fakeSpan{name: "root"})
+			inst := serverInstruction{StatusCode: 200}
+			_, err := cl.Do(inst.toPlan(ctx, "", httpServer))
+
+			require.NoError(t, err)
+			require.Len(t, traceIDs, 1)
+			assert.Equal(t, testCase.began, traceIDs[0] != "")
+			assert.Equal(t, uint64(1), p.Stats().AttemptsTraced)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/v2/header"
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+)
+
+// A TraceContext identifies the attempt subsegment which is the parent
+// of the work done by a downstream service, and is what a Propagator
+// sends downstream.
+type TraceContext struct {
+	// TraceID is the X-Ray trace ID, for example
+	// "1-5759e988-bd862e3fe1be46a994272793".
+	TraceID string
+
+	// ParentID is the 16 hexadecimal digit ID of the attempt
+	// subsegment.
+	ParentID string
+
+	// Sampled indicates whether the trace is sampled.
+	Sampled bool
+
+	// AdditionalData holds any other key/value pairs of the
+	// X-Amzn-Trace-Id header received by the service, such as Lineage,
+	// which are passed on downstream by XRayPropagator.
+	AdditionalData map[string]string
+}
+
+// A Propagator writes the trace context of a request attempt into the
+// attempt's outgoing HTTP request headers, so that the downstream
+// service can continue the trace.
+//
+// Inject is called once for each request attempt, after the attempt
+// subsegment has started and before the request is sent. It may
+// inspect the request, for example to choose headers according to the
+// destination host, but should only modify the request headers.
+//
+// Implementations of Propagator must be safe for concurrent use by
+// multiple goroutines.
+type Propagator interface {
+	Inject(req *http.Request, tc TraceContext)
+}
+
+// The PropagatorFunc type is an adapter to allow the use of ordinary
+// functions as propagators. If f is a function with appropriate
+// signature, then PropagatorFunc(f) is a Propagator that calls f.
+type PropagatorFunc func(req *http.Request, tc TraceContext)
+
+// Inject calls f(req, tc).
+func (f PropagatorFunc) Inject(req *http.Request, tc TraceContext) {
+	f(req, tc)
+}
+
+var (
+	// XRayPropagator is a Propagator which sets the X-Amzn-Trace-Id
+	// header understood by AWS X-Ray. XRayPropagator is the default
+	// Propagator.
+	XRayPropagator Propagator = PropagatorFunc(injectXRay)
+
+	// W3CPropagator is a Propagator which sets the traceparent header
+	// defined by the W3C Trace Context specification, as understood by
+	// OpenTelemetry. The W3C trace ID is the X-Ray trace ID with its
+	// version and dashes removed, and the parent ID is the attempt
+	// subsegment ID, so the trace continues seamlessly in services that
+	// export OpenTelemetry traces to X-Ray. The additional data of the
+	// X-Amzn-Trace-Id header has no W3C equivalent, so it is not sent,
+	// and the tracestate header is not set.
+	W3CPropagator Propagator = PropagatorFunc(injectW3C)
+)
+
+// ChainPropagators returns a Propagator which applies each of the given
+// propagators in turn. Nil propagators are skipped. For example, to
+// send both the X-Amzn-Trace-Id and the traceparent headers:
+//
+//	httpxxray.ChainPropagators(httpxxray.XRayPropagator, httpxxray.W3CPropagator)
+func ChainPropagators(p ...Propagator) Propagator {
+	chain := make([]Propagator, 0, len(p))
+	for i := range p {
+		if p[i] != nil {
+			chain = append(chain, p[i])
+		}
+	}
+	return PropagatorFunc(func(req *http.Request, tc TraceContext) {
+		for i := range chain {
+			chain[i].Inject(req, tc)
+		}
+	})
+}
+
+// NewHostPropagator returns a Propagator which chooses a propagator
+// according to the destination host of each request. The hosts table
+// maps host patterns to propagators, and is copied, so later changes to
+// it have no effect on the returned propagator. Requests to hosts which
+// match no pattern in the table use fallback. A nil propagator, whether
+// in the table or as fallback, injects no headers.
+//
+// Host patterns are matched case-insensitively against the request host
+// name, without any port. The request host is the Host header, if set,
+// as it is for a request plan with a Host, and otherwise the URL host,
+// so a request sent to an IP address or a proxy is matched by the host
+// it is addressed to. A pattern is either an exact host name, such
+// as "api.example.com", a wildcard such as "*.example.com", which
+// matches every subdomain of example.com but not example.com itself, or
+// the wildcard "*", which matches every host. When several patterns
+// match, the most specific one is used: an exact host name is preferred
+// over a wildcard, and a longer wildcard over a shorter one. The
+// function panics if a pattern is not valid.
+//
+// For example, to send only the traceparent header to OpenTelemetry
+// services while sending X-Amzn-Trace-Id everywhere else:
+//
+//	httpxxray.NewHostPropagator(map[string]httpxxray.Propagator{
+//		"*.otel.example.com": httpxxray.W3CPropagator,
+//	}, httpxxray.XRayPropagator)
+func NewHostPropagator(hosts map[string]Propagator, fallback Propagator) Propagator {
+	t := make(map[string]Propagator, len(hosts))
+	for k, v := range hosts {
+		t[hostPattern(k)] = v
+	}
+	return PropagatorFunc(func(req *http.Request, tc TraceContext) {
+		p := fallback
+		if pattern, ok := matchHost(requestHostname(req), func(pattern string) bool {
+			_, ok := t[pattern]
+			return ok
+		}); ok {
+			p = t[pattern]
+		}
+		if p != nil {
+			p.Inject(req, tc)
+		}
+	})
+}
+
+// NewHostAllowlist returns a Propagator which applies p only to requests
+// whose destination host matches one of the given host patterns, and
+// injects no headers into other requests. Host patterns have the same
+// syntax as for NewHostPropagator. For example, to send trace headers
+// only to internal services:
+//
+//	httpxxray.NewHostAllowlist(httpxxray.XRayPropagator, "*.internal.example.com")
+//
+// Since a Propagator only controls which headers are sent, the attempt
+// subsegment is recorded whether or not headers are injected.
+func NewHostAllowlist(p Propagator, hosts ...string) Propagator {
+	return newHostList(p, hosts, true)
+}
+
+// NewHostDenylist returns a Propagator which applies p to every request
+// except those whose destination host matches one of the given host
+// patterns. Host patterns have the same syntax as for NewHostPropagator.
+// For example, to avoid leaking trace IDs to a third party API:
+//
+//	httpxxray.NewHostDenylist(httpxxray.XRayPropagator, "*.vendor.com")
+//
+// Since a Propagator only controls which headers are sent, the attempt
+// subsegment is recorded whether or not headers are injected.
+func NewHostDenylist(p Propagator, hosts ...string) Propagator {
+	return newHostList(p, hosts, false)
+}
+
+func newHostList(p Propagator, hosts []string, allow bool) Propagator {
+	set := make(map[string]bool, len(hosts))
+	for i := range hosts {
+		set[hostPattern(hosts[i])] = true
+	}
+	return PropagatorFunc(func(req *http.Request, tc TraceContext) {
+		_, match := matchHost(requestHostname(req), func(pattern string) bool {
+			return set[pattern]
+		})
+		if match == allow && p != nil {
+			p.Inject(req, tc)
+		}
+	})
+}
+
+// requestHostname returns the host name, without any port, which req is
+// addressed to, taken from the Host header if set and otherwise from the
+// URL. The same host is used to name the execution subsegment.
+func requestHostname(req *http.Request) string {
+	if req.Host == "" {
+		return req.URL.Hostname()
+	}
+
+	return (&url.URL{Host: req.Host}).Hostname()
+}
+
+// hostPattern validates and normalizes a host pattern. It panics if the
+// pattern is not valid.
+func hostPattern(pattern string) string {
+	p := strings.ToLower(pattern)
+	if p == "*" {
+		return p
+	}
+
+	p = strings.TrimSuffix(p, ".")
+	suffix := strings.TrimPrefix(p, "*.")
+	if suffix != "" && !strings.Contains(suffix, "*") {
+		return p
+	}
+
+	panic("httpxxray: invalid host pattern: " + pattern)
+}
+
+// matchHost returns the most specific host pattern which matches host
+// and for which has returns true.
+func matchHost(host string, has func(pattern string) bool) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if has(host) {
+		return host, true
+	}
+
+	rest := host
+	for {
+		i := strings.IndexByte(rest, '.')
+		if i < 0 {
+			break
+		}
+		rest = rest[i+1:]
+		if pattern := "*." + rest; has(pattern) {
+			return pattern, true
+		}
+	}
+
+	if has("*") {
+		return "*", true
+	}
+
+	return "", false
+}
+
+func injectXRay(req *http.Request, tc TraceContext) {
+	if tc.TraceID == "" {
+		return
+	}
+
+	h := header.Header{
+		TraceID:          tc.TraceID,
+		ParentID:         tc.ParentID,
+		SamplingDecision: header.NotSampled,
+		AdditionalData:   tc.AdditionalData,
+	}
+	if tc.Sampled {
+		h.SamplingDecision = header.Sampled
+	}
+	req.Header.Set(xray.TraceIDHeaderKey, h.String())
+}
+
+const traceparentHeaderKey = "traceparent"
+
+func injectW3C(req *http.Request, tc TraceContext) {
+	traceID, ok := w3cTraceID(tc.TraceID)
+	if !ok || !isW3CID(tc.ParentID, 16) {
+		return
+	}
+
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	req.Header.Set(traceparentHeaderKey, "00-"+traceID+"-"+tc.ParentID+"-"+flags)
+}
+
+// w3cTraceID converts an X-Ray trace ID, such as
+// "1-5759e988-bd862e3fe1be46a994272793", into a W3C trace ID, such as
+// "5759e988bd862e3fe1be46a994272793".
+func w3cTraceID(xrayTraceID string) (string, bool) {
+	parts := strings.Split(xrayTraceID, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return "", false
+	}
+
+	id := parts[1] + parts[2]
+	return id, isW3CID(id, 32)
+}
+
+// isW3CID reports whether id is a valid W3C trace or parent ID having
+// n lowercase hexadecimal digits, not all of which are zero.
+func isW3CID(id string, n int) bool {
+	if len(id) != n {
+		return false
+	}
+
+	nonZero := false
+	for _, r := range id {
+		switch {
+		case r == '0':
+		case '1' <= r && r <= '9', 'a' <= r && r <= 'f':
+			nonZero = true
+		default:
+			return false
+		}
+	}
+	return nonZero
+}
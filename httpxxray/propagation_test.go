@@ -0,0 +1,230 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagators(t *testing.T) {
+	sampled := TraceContext{
+		TraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+		ParentID: "53995c3f42cd8ad8",
+		Sampled:  true,
+	}
+	notSampled := sampled
+	notSampled.Sampled = false
+	badTraceID := sampled
+	badTraceID.TraceID = "1-5759e988-BD862E3FE1BE46A994272793"
+	zeroTraceID := sampled
+	zeroTraceID.TraceID = "1-00000000-000000000000000000000000"
+	badParentID := sampled
+	badParentID.ParentID = "53995c3f42cd8ad"
+	withData := sampled
+	withData.AdditionalData = map[string]string{"Lineage": "a87bd80c:1|68fd508a:5"}
+	testCases := []struct {
+		name       string
+		propagator Propagator
+		tc         TraceContext
+		header     http.Header
+	}{
+		{
+			name:       "XRayPropagator[Sampled]",
+			propagator: XRayPropagator,
+			tc:         sampled,
+			header:     http.Header{"X-Amzn-Trace-Id": {"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"}},
+		},
+		{
+			name:       "XRayPropagator[NotSampled]",
+			propagator: XRayPropagator,
+			tc:         notSampled,
+			header:     http.Header{"X-Amzn-Trace-Id": {"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0"}},
+		},
+		{
+			name:       "XRayPropagator[AdditionalData]",
+			propagator: XRayPropagator,
+			tc:         withData,
+			header:     http.Header{"X-Amzn-Trace-Id": {"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;Lineage=a87bd80c:1|68fd508a:5"}},
+		},
+		{
+			name:       "W3CPropagator[AdditionalData]",
+			propagator: W3CPropagator,
+			tc:         withData,
+			header:     http.Header{"Traceparent": {"00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"}},
+		},
+		{
+			name:       "XRayPropagator[Empty]",
+			propagator: XRayPropagator,
+			header:     http.Header{},
+		},
+		{
+			name:       "W3CPropagator[Sampled]",
+			propagator: W3CPropagator,
+			tc:         sampled,
+			header:     http.Header{"Traceparent": {"00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"}},
+		},
+		{
+			name:       "W3CPropagator[NotSampled]",
+			propagator: W3CPropagator,
+			tc:         notSampled,
+			header:     http.Header{"Traceparent": {"00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-00"}},
+		},
+		{"W3CPropagator[Empty]", W3CPropagator, TraceContext{}, http.Header{}},
+		{"W3CPropagator[BadTraceID]", W3CPropagator, badTraceID, http.Header{}},
+		{"W3CPropagator[ZeroTraceID]", W3CPropagator, zeroTraceID, http.Header{}},
+		{"W3CPropagator[BadParentID]", W3CPropagator, badParentID, http.Header{}},
+		{
+			name:       "ChainPropagators",
+			propagator: ChainPropagators(XRayPropagator, nil, W3CPropagator),
+			tc:         sampled,
+			header: http.Header{
+				"X-Amzn-Trace-Id": {"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"},
+				"Traceparent":     {"00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"},
+			},
+		},
+		{"ChainPropagators[Empty]", ChainPropagators(), sampled, http.Header{}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "http://foo.com", nil)
+			require.NoError(t, err)
+
+			testCase.propagator.Inject(req, testCase.tc)
+
+			assert.Equal(t, testCase.header, req.Header)
+		})
+	}
+}
+
+func TestNewHostPropagator(t *testing.T) {
+	hosts := map[string]Propagator{
+		"OTel.Example.com": W3CPropagator,
+		"*.example.com":    ChainPropagators(XRayPropagator, W3CPropagator),
+		"*.b.example.com":  W3CPropagator,
+		"quiet.com":        nil,
+	}
+	p := NewHostPropagator(hosts, XRayPropagator)
+	hosts["foo.com"] = nil
+	tc := TraceContext{
+		TraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+		ParentID: "53995c3f42cd8ad8",
+	}
+	testCases := []struct {
+		url     string
+		headers []string
+	}{
+		{"http://otel.example.com:8080/bar", []string{"Traceparent"}},
+		{"https://OTEL.EXAMPLE.COM.", []string{"Traceparent"}},
+		{"http://a.example.com", []string{"Traceparent", "X-Amzn-Trace-Id"}},
+		{"http://a.b.example.com", []string{"Traceparent"}},
+		{"http://b.example.com", []string{"Traceparent", "X-Amzn-Trace-Id"}},
+		{"http://foo.com", []string{"X-Amzn-Trace-Id"}},
+		{"http://example.com", []string{"X-Amzn-Trace-Id"}},
+		{"http://quiet.com", nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			assert.Equal(t, testCase.headers, injectedHeaders(t, p, testCase.url, tc))
+		})
+	}
+	t.Run("invalid pattern", func(t *testing.T) {
+		for _, pattern := range []string{"", "*.", "a.*.com", "*a.com", "**"} {
+			assert.Panics(t, func() {
+				NewHostPropagator(map[string]Propagator{pattern: nil}, nil)
+			}, pattern)
+		}
+	})
+}
+
+func TestHostLists(t *testing.T) {
+	tc := TraceContext{
+		TraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+		ParentID: "53995c3f42cd8ad8",
+	}
+	xrayHeader := []string{"X-Amzn-Trace-Id"}
+	testCases := []struct {
+		name       string
+		propagator Propagator
+		url        string
+		headers    []string
+	}{
+		{"Allowlist[Exact]", NewHostAllowlist(XRayPropagator, "foo.com"), "http://FOO.com:80", xrayHeader},
+		{"Allowlist[Wildcard]", NewHostAllowlist(XRayPropagator, "*.foo.com"), "http://a.b.foo.com", xrayHeader},
+		{"Allowlist[NoMatch]", NewHostAllowlist(XRayPropagator, "*.foo.com"), "http://foo.com", nil},
+		{"Allowlist[Empty]", NewHostAllowlist(XRayPropagator), "http://foo.com", nil},
+		{"Allowlist[All]", NewHostAllowlist(XRayPropagator, "*"), "http://foo.com", xrayHeader},
+		{"Allowlist[nil]", NewHostAllowlist(nil, "*"), "http://foo.com", nil},
+		{"Denylist[Exact]", NewHostDenylist(XRayPropagator, "foo.com"), "http://foo.com", nil},
+		{"Denylist[Wildcard]", NewHostDenylist(XRayPropagator, "*.foo.com"), "http://api.foo.com", nil},
+		{"Denylist[NoMatch]", NewHostDenylist(XRayPropagator, "*.foo.com"), "http://foo.com", xrayHeader},
+		{"Denylist[Empty]", NewHostDenylist(XRayPropagator), "http://foo.com", xrayHeader},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.headers, injectedHeaders(t, testCase.propagator, testCase.url, tc))
+		})
+	}
+}
+
+func TestHostLists_HostHeader(t *testing.T) {
+	tc := TraceContext{
+		TraceID:  "1-5759e988-bd862e3fe1be46a994272793",
+		ParentID: "53995c3f42cd8ad8",
+	}
+	deny := NewHostDenylist(XRayPropagator, "*.vendor.com")
+	allow := NewHostAllowlist(XRayPropagator, "*.vendor.com")
+	testCases := []struct {
+		name    string
+		host    string
+		headers []string
+	}{
+		{"Host", "api.vendor.com", nil},
+		{"Host with port", "API.vendor.com:8443", nil},
+		{"IPv6 host with port", "[::1]:8443", []string{"X-Amzn-Trace-Id"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			newRequest := func() *http.Request {
+				p, err := request.NewPlan("GET", "https://10.0.0.1/foo", nil)
+				require.NoError(t, err)
+				p.Host = testCase.host
+				return p.ToRequest(context.Background())
+			}
+
+			req := newRequest()
+			deny.Inject(req, tc)
+			assert.Equal(t, testCase.headers, headerNames(req.Header))
+
+			req = newRequest()
+			allow.Inject(req, tc)
+			assert.Equal(t, testCase.headers == nil, req.Header.Get("X-Amzn-Trace-Id") != "")
+		})
+	}
+}
+
+func injectedHeaders(t *testing.T, p Propagator, url string, tc TraceContext) []string {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+
+	p.Inject(req, tc)
+
+	return headerNames(req.Header)
+}
+
+func headerNames(h http.Header) []string {
+	var names []string
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
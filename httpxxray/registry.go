@@ -0,0 +1,58 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+// +build go1.24
+
+package httpxxray
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"weak"
+
+	"github.com/gogama/httpx"
+)
+
+// registry remembers the plugin installed on each handler group for
+// each tracing backend. It refers to handler groups weakly, so that it
+// does not keep them reachable, and forgets each handler group once it
+// is collected.
+var registry = struct {
+	sync.Mutex
+	plugins map[registryKey]*Plugin
+}{
+	plugins: make(map[registryKey]*Plugin),
+}
+
+type registryKey struct {
+	handlers weak.Pointer[httpx.HandlerGroup]
+	backend  reflect.Type
+}
+
+// lookupPlugin returns the plugin installed on handlers with the given
+// tracing backend, or nil if no such plugin has ever been installed on
+// handlers.
+func lookupPlugin(handlers *httpx.HandlerGroup, backend reflect.Type) *Plugin {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.plugins[registryKey{weak.Make(handlers), backend}]
+}
+
+// storePlugin remembers that p is installed on handlers with the given
+// tracing backend.
+func storePlugin(handlers *httpx.HandlerGroup, backend reflect.Type, p *Plugin) {
+	key := registryKey{weak.Make(handlers), backend}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.plugins[key] = p
+	runtime.AddCleanup(handlers, forgetPlugin, key)
+}
+
+func forgetPlugin(key registryKey) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.plugins, key)
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.24
+// +build !go1.24
+
+package httpxxray
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/gogama/httpx"
+)
+
+// registry remembers the plugin installed on each handler group for
+// each tracing backend. Before Go 1.24, there are no weak pointers, so
+// the registry keeps each handler group reachable for the life of the
+// program.
+var registry = struct {
+	sync.Mutex
+	plugins map[registryKey]*Plugin
+}{
+	plugins: make(map[registryKey]*Plugin),
+}
+
+type registryKey struct {
+	handlers *httpx.HandlerGroup
+	backend  reflect.Type
+}
+
+// lookupPlugin returns the plugin installed on handlers with the given
+// tracing backend, or nil if no such plugin has ever been installed on
+// handlers.
+func lookupPlugin(handlers *httpx.HandlerGroup, backend reflect.Type) *Plugin {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.plugins[registryKey{handlers, backend}]
+}
+
+// storePlugin remembers that p is installed on handlers with the given
+// tracing backend.
+func storePlugin(handlers *httpx.HandlerGroup, backend reflect.Type, p *Plugin) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.plugins[registryKey{handlers, backend}] = p
+}
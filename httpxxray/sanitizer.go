@@ -0,0 +1,158 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// A URLSanitizer removes or masks sensitive parts of a URL before the
+// plugin records it in an X-Ray trace.
+//
+// Sanitize receives a copy of the URL and returns the sanitized URL.
+// Because the input is a copy, implementations may freely modify it
+// and return it.
+//
+// Implementations of URLSanitizer must be safe for concurrent use by
+// multiple goroutines.
+type URLSanitizer interface {
+	Sanitize(u url.URL) url.URL
+}
+
+// The URLSanitizerFunc type is an adapter to allow the use of ordinary
+// functions as URL sanitizers. If f is a function with appropriate
+// signature, then URLSanitizerFunc(f) is a URLSanitizer that calls f.
+type URLSanitizerFunc func(u url.URL) url.URL
+
+// Sanitize calls f(u).
+func (f URLSanitizerFunc) Sanitize(u url.URL) url.URL {
+	return f(u)
+}
+
+var (
+	// DefaultSanitizer is the URLSanitizer used if no other sanitizer
+	// is configured. It removes both the username and password and the
+	// entire query string from the URL, since either may contain
+	// credentials.
+	DefaultSanitizer = ChainSanitizers(StripUserinfo, StripQuery)
+
+	// StripQuery is a URLSanitizer which removes the entire query
+	// string from the URL.
+	StripQuery URLSanitizer = URLSanitizerFunc(stripQuery)
+
+	// StripUserinfo is a URLSanitizer which removes the username and
+	// password, if any, from the URL.
+	StripUserinfo URLSanitizer = URLSanitizerFunc(stripUserinfo)
+
+	// KeepURL is a URLSanitizer which records the URL exactly as sent.
+	// Use it only if you are sure your URLs never contain sensitive
+	// data.
+	KeepURL URLSanitizer = URLSanitizerFunc(func(u url.URL) url.URL { return u })
+)
+
+// ChainSanitizers returns a URLSanitizer which applies each of the
+// given sanitizers in turn, passing the output of each one to the
+// next. Nil sanitizers are skipped.
+func ChainSanitizers(s ...URLSanitizer) URLSanitizer {
+	chain := make([]URLSanitizer, 0, len(s))
+	for i := range s {
+		if s[i] != nil {
+			chain = append(chain, s[i])
+		}
+	}
+	return URLSanitizerFunc(func(u url.URL) url.URL {
+		for i := range chain {
+			u = chain[i].Sanitize(u)
+		}
+		return u
+	})
+}
+
+// NewQueryAllowlist returns a URLSanitizer which removes every query
+// parameter from the URL except those whose names are listed in
+// params. Parameter name matching is case-sensitive. The surviving
+// parameters are re-encoded in order sorted by name.
+func NewQueryAllowlist(params ...string) URLSanitizer {
+	allow := make(map[string]bool, len(params))
+	for _, p := range params {
+		allow[p] = true
+	}
+	return URLSanitizerFunc(func(u url.URL) url.URL {
+		if u.RawQuery == "" {
+			return u
+		}
+		q := u.Query()
+		for k := range q {
+			if !allow[k] {
+				delete(q, k)
+			}
+		}
+		u.RawQuery = q.Encode()
+		u.ForceQuery = false
+		return u
+	})
+}
+
+// NewPathMasker returns a URLSanitizer which replaces every URL path
+// segment matching pattern with mask. The pattern is matched against
+// each unescaped path segment individually, so anchor it with ^ and $
+// to require a whole-segment match.
+//
+// For example, the following masker changes the path
+// "/tokens/abc123" into "/tokens/*":
+//
+//	httpxxray.NewPathMasker(regexp.MustCompile(`^[a-z]+[0-9]+$`), "*")
+func NewPathMasker(pattern *regexp.Regexp, mask string) URLSanitizer {
+	if pattern == nil {
+		panic("httpxxray: nil path mask pattern")
+	}
+	escapedMask := (&url.URL{Path: mask}).EscapedPath()
+	return URLSanitizerFunc(func(u url.URL) url.URL {
+		segs := strings.Split(u.EscapedPath(), "/")
+		masked := false
+		for i := range segs {
+			if segs[i] == "" {
+				continue
+			}
+			seg, err := url.PathUnescape(segs[i])
+			if err != nil {
+				seg = segs[i]
+			}
+			if pattern.MatchString(seg) {
+				segs[i] = escapedMask
+				masked = true
+			}
+		}
+		if masked {
+			rawPath := strings.Join(segs, "/")
+			if path, err := url.PathUnescape(rawPath); err == nil {
+				u.Path, u.RawPath = path, rawPath
+			}
+		}
+		return u
+	})
+}
+
+func stripQuery(u url.URL) url.URL {
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u
+}
+
+func stripUserinfo(u url.URL) url.URL {
+	u.User = nil
+	return u
+}
+
+func sanitizeURL(s URLSanitizer, u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	v := s.Sanitize(*u)
+	return v.String()
+}
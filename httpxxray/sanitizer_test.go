@@ -0,0 +1,77 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSanitizers(t *testing.T) {
+	tokenMasker := NewPathMasker(regexp.MustCompile(`^[a-z]*[0-9]+[a-z0-9]*$`), "*")
+	testCases := []struct {
+		name      string
+		sanitizer URLSanitizer
+		in        string
+		out       string
+	}{
+		{"DefaultSanitizer", DefaultSanitizer, "http://u:p@foo.com/bar?baz=qux", "http://foo.com/bar"},
+		{"StripQuery", StripQuery, "http://u:p@foo.com/bar?baz=qux", "http://u:p@foo.com/bar"},
+		{"StripQuery[ForceQuery]", StripQuery, "http://foo.com/bar?", "http://foo.com/bar"},
+		{"StripUserinfo", StripUserinfo, "http://u:p@foo.com/bar?baz=qux", "http://foo.com/bar?baz=qux"},
+		{"KeepURL", KeepURL, "http://u:p@foo.com/bar?baz=qux", "http://u:p@foo.com/bar?baz=qux"},
+		{"QueryAllowlist[Empty]", NewQueryAllowlist(), "http://foo.com/?a=1&b=2", "http://foo.com/"},
+		{"QueryAllowlist[NoQuery]", NewQueryAllowlist("a"), "http://foo.com/bar", "http://foo.com/bar"},
+		{"QueryAllowlist[Some]", NewQueryAllowlist("a", "c"), "http://foo.com/?c=3&b=2&a=1&a=11", "http://foo.com/?a=1&a=11&c=3"},
+		{"QueryAllowlist[CaseSensitive]", NewQueryAllowlist("a"), "http://foo.com/?A=1", "http://foo.com/"},
+		{"PathMasker[NoMatch]", tokenMasker, "http://foo.com/tokens/", "http://foo.com/tokens/"},
+		{"PathMasker[Match]", tokenMasker, "http://foo.com/tokens/abc123?x=y", "http://foo.com/tokens/*?x=y"},
+		{"PathMasker[MultiMatch]", tokenMasker, "http://foo.com/users/42/tokens/abc123", "http://foo.com/users/*/tokens/*"},
+		{"PathMasker[Escaped]", tokenMasker, "http://foo.com/a%2Fb/123", "http://foo.com/a%2Fb/*"},
+		{
+			"ChainSanitizers",
+			ChainSanitizers(StripUserinfo, nil, NewQueryAllowlist("a"), tokenMasker),
+			"http://u:p@foo.com/tokens/abc123?a=1&b=2",
+			"http://foo.com/tokens/*?a=1",
+		},
+		{"ChainSanitizers[Empty]", ChainSanitizers(), "http://u:p@foo.com/?a=1", "http://u:p@foo.com/?a=1"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			u, err := url.Parse(testCase.in)
+			require.NoError(t, err)
+
+			out := sanitizeURL(testCase.sanitizer, u)
+
+			assert.Equal(t, testCase.out, out)
+			assert.Equal(t, testCase.in, u.String(), "input URL must not be modified")
+		})
+	}
+}
+
+func TestNewPathMasker(t *testing.T) {
+	assert.PanicsWithValue(t, "httpxxray: nil path mask pattern", func() {
+		NewPathMasker(nil, "*")
+	})
+}
+
+func TestURLSanitizerFunc(t *testing.T) {
+	f := URLSanitizerFunc(func(u url.URL) url.URL {
+		u.Host = "bar.com"
+		return u
+	})
+	u, err := url.Parse("http://foo.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "http://bar.com", sanitizeURL(f, u))
+}
+
+func TestSanitizeURL(t *testing.T) {
+	assert.Equal(t, "", sanitizeURL(KeepURL, nil))
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package httpxxray
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// NewSlogLogger returns a StructuredLogger which logs to the given
+// log/slog Logger. Structured messages are logged at the corresponding
+// slog level, with one attribute per field. Messages logged using Printf
+// are logged at the Info level. If l is nil, slog.Default() is used.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Printf(format string, v ...interface{}) {
+	s.logger().Info(fmt.Sprintf(format, v...))
+}
+
+func (s slogLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i := range fields {
+		attrs[i] = slog.Any(fields[i].Key, fields[i].Value)
+	}
+	s.logger().LogAttrs(ctx, slog.Level(level), msg, attrs...)
+}
+
+func (s slogLogger) logger() *slog.Logger {
+	if s.l == nil {
+		return slog.Default()
+	}
+
+	return s.l
+}
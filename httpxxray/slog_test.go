@@ -0,0 +1,64 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package httpxxray
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	t.Run("Log", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+		l.Log(context.Background(), LevelWarn, subsegmentNotStartedMsg, Field{"event", "BeforeAttempt"}, Field{"attempt", 1})
+
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+		assert.Equal(t, "WARN", rec["level"])
+		assert.Equal(t, subsegmentNotStartedMsg, rec["msg"])
+		assert.Equal(t, "BeforeAttempt", rec["event"])
+		assert.Equal(t, float64(1), rec["attempt"])
+	})
+	t.Run("Log[Level filtered]", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError})))
+
+		l.Log(context.Background(), LevelWarn, "foo")
+
+		assert.Empty(t, buf.String())
+	})
+	t.Run("Printf", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+		l.Printf("foo %s", "bar")
+
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+		assert.Equal(t, "INFO", rec["level"])
+		assert.Equal(t, "foo bar", rec["msg"])
+	})
+	t.Run("nil", func(t *testing.T) {
+		var buf bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+		l := NewSlogLogger(nil)
+
+		l.Log(context.Background(), LevelError, "foo")
+
+		assert.Contains(t, buf.String(), `"msg":"foo"`)
+	})
+}
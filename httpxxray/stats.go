@@ -0,0 +1,72 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"sync/atomic"
+
+	"github.com/gogama/httpx"
+)
+
+// Stats is a snapshot of the health counters of an installed plugin.
+// The counters start at zero when the plugin is installed and only
+// increase. Alarm on them to detect tracing breaking silently, for
+// example because request plans are created without an X-Ray aware
+// context.
+type Stats struct {
+	// ExecutionsTraced is the number of request plan executions for
+	// which the execution subsegment was begun.
+	ExecutionsTraced uint64
+
+	// AttemptsTraced is the number of request attempts for which the
+	// attempt subsegment was begun.
+	AttemptsTraced uint64
+
+	// NotStarted counts, by httpx event, the subsegments which the
+	// plugin could not begin, usually because the context did not
+	// contain a parent segment. Events for which no subsegment failed
+	// to begin are omitted.
+	NotStarted map[httpx.Event]uint64
+
+	// ClosedWithError is the number of subsegments closed with an
+	// error recorded as their cause.
+	ClosedWithError uint64
+
+	// MissingOnClose is the number of times the plugin found no
+	// subsegment to close at the end of a request attempt or plan
+	// execution, for example because the subsegment was never begun.
+	MissingOnClose uint64
+}
+
+// numEvents is the number of httpx event types.
+const numEvents = int(httpx.AfterExecutionEnd) + 1
+
+// counters holds the plugin's health counters. Its fields are updated
+// atomically. Since it is always allocated separately, and contains only
+// 64-bit words, they are correctly aligned for atomic access on 32-bit
+// platforms.
+type counters struct {
+	executionsTraced uint64
+	attemptsTraced   uint64
+	notStarted       [numEvents]uint64
+	closedWithError  uint64
+	missingOnClose   uint64
+}
+
+func (c *counters) snapshot() Stats {
+	s := Stats{
+		ExecutionsTraced: atomic.LoadUint64(&c.executionsTraced),
+		AttemptsTraced:   atomic.LoadUint64(&c.attemptsTraced),
+		NotStarted:       make(map[httpx.Event]uint64),
+		ClosedWithError:  atomic.LoadUint64(&c.closedWithError),
+		MissingOnClose:   atomic.LoadUint64(&c.missingOnClose),
+	}
+	for i := range c.notStarted {
+		if n := atomic.LoadUint64(&c.notStarted[i]); n > 0 {
+			s.NotStarted[httpx.Event(i)] = n
+		}
+	}
+	return s
+}
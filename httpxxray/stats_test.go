@@ -0,0 +1,49 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gogama/httpx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters_snapshot(t *testing.T) {
+	c := &counters{
+		executionsTraced: 1,
+		attemptsTraced:   2,
+		closedWithError:  3,
+		missingOnClose:   4,
+	}
+	c.notStarted[httpx.BeforeAttempt] = 5
+	c.notStarted[httpx.BeforeReadBody] = 6
+
+	s := c.snapshot()
+
+	assert.Equal(t, Stats{
+		ExecutionsTraced: 1,
+		AttemptsTraced:   2,
+		NotStarted: map[httpx.Event]uint64{
+			httpx.BeforeAttempt:  5,
+			httpx.BeforeReadBody: 6,
+		},
+		ClosedWithError: 3,
+		MissingOnClose:  4,
+	}, s)
+}
+
+func TestHandler_close(t *testing.T) {
+	h := newHandler(Config{})
+	s1, s2 := &fakeSpan{}, &fakeSpan{}
+
+	h.close(s1, nil, Classification{})
+	h.close(s2, errors.New("boom"), Classification{Fault: true})
+
+	assert.True(t, s1.closed)
+	assert.True(t, s2.closed)
+	assert.Equal(t, uint64(1), h.stats.snapshot().ClosedWithError)
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"net/http/httptrace"
+	"time"
+)
+
+// A Tracer is the tracing backend which records the spans produced by
+// the plugin.
+//
+// The plugin implements the httpx plan execution lifecycle (execution,
+// attempts, timeouts, racing, and so on) purely in terms of Tracer and
+// Span, so the same lifecycle can be recorded by different backends.
+// The default Tracer records each span as an X-Ray subsegment using the
+// AWS X-Ray SDK for Go. Package httpxotel, in the module
+// github.com/gogama/aws-xray-httpx/httpxotel, provides a Tracer which
+// records OpenTelemetry spans instead. Most programs never need to
+// implement Tracer themselves.
+//
+// Implementations of Tracer must be safe for concurrent use by multiple
+// goroutines.
+//
+// Methods are never added to the Tracer, Span and ConnTrace interfaces,
+// so that existing implementations keep compiling. Instead, newer
+// capabilities are described by optional interfaces, such as AWSSpan
+// and ConnTraceCloser, which the plugin uses when a Span or ConnTrace
+// implements them.
+type Tracer interface {
+	// Begin starts a new span of the given kind, named name, as a
+	// child of the span in ctx, and returns a context containing the
+	// new span. If start is not the zero time, it is the span's start
+	// time, otherwise the span starts now.
+	//
+	// If the backend cannot start the span, for example because ctx
+	// does not contain a parent span and the backend requires one,
+	// Begin returns a nil Span.
+	Begin(ctx context.Context, kind SpanKind, name string, start time.Time) (context.Context, Span)
+
+	// Get returns the span in ctx, or nil if ctx does not contain one.
+	Get(ctx context.Context) Span
+
+	// TraceHTTP returns a ConnTrace which records the low-level HTTP
+	// operations (DNS lookup, connect, TLS handshake, etc.) of a single
+	// request attempt as part of the span in ctx.
+	TraceHTTP(ctx context.Context) ConnTrace
+}
+
+// A SpanKind identifies the part of the httpx plan execution lifecycle
+// which a span represents. Backends use it to map the span onto their
+// own concepts, for example the X-Ray subsegment namespace or the
+// OpenTelemetry span kind.
+type SpanKind int
+
+const (
+	// ExecutionSpan represents the whole request plan execution. It
+	// is the parent of all the other spans.
+	ExecutionSpan SpanKind = iota
+	// AttemptSpan represents a single HTTP request attempt.
+	AttemptSpan
+	// ReadBodySpan represents reading the response body within a
+	// request attempt. It is a child of the AttemptSpan.
+	ReadBodySpan
+	// BackoffSpan represents the retry wait period preceding a new wave
+	// of request attempts.
+	BackoffSpan
+)
+
+// A Span is a single timed operation recorded by a Tracer. In X-Ray
+// terms, a span is a subsegment.
+//
+// Implementations of Span must be safe for concurrent use by multiple
+// goroutines.
+type Span interface {
+	// SetHTTPRequest records the HTTP request method and URL. The URL
+	// has already been sanitized by the configured URLSanitizer.
+	SetHTTPRequest(method, url string)
+
+	// SetHTTPResponse records the HTTP response status code and content
+	// length.
+	SetHTTPResponse(status, contentLength int)
+
+	// AddAnnotation records an indexed key/value pair on the span.
+	AddAnnotation(key string, value interface{})
+
+	// AddMetadata records a non-indexed key/value pair on the span
+	// within the given namespace.
+	AddMetadata(namespace, key string, value interface{})
+
+	// TraceContext returns the trace context which a Propagator sends
+	// to a downstream service called within the span.
+	TraceContext() TraceContext
+
+	// Close ends the span. If err is not nil, it is recorded as the
+	// cause of the span's failure. The span's fault, error and throttle
+	// flags are set exactly as given by c.
+	Close(err error, c Classification)
+}
+
+// A ConnTrace records the low-level HTTP operations of a request
+// attempt.
+//
+// Implementations of ConnTrace must be safe for concurrent use by
+// multiple goroutines, since the httptrace hooks may be called from
+// different goroutines.
+type ConnTrace interface {
+	// ClientTrace returns the httptrace hooks to install on the request
+	// attempt's context.
+	ClientTrace() *httptrace.ClientTrace
+}
+
+// An AWSSpan is a Span which records calls to AWS service endpoints.
+// The plugin calls SetAWS on spans which implement AWSSpan.
+type AWSSpan interface {
+	Span
+
+	// SetAWS records that the span is a call to an AWS service
+	// endpoint. The execution span of such a call represents the AWS
+	// service rather than a generic remote service.
+	SetAWS(call AWSCall)
+}
+
+// A ConnTraceCloser is a ConnTrace which is told when the request
+// attempt ends. The plugin calls Close on ConnTraces which implement
+// ConnTraceCloser.
+type ConnTraceCloser interface {
+	ConnTrace
+
+	// Close is called when the request attempt ends, before the attempt
+	// span is closed. It ends any operation still in progress, such as
+	// a connect interrupted by the attempt failing, recording err, if
+	// not nil, as the cause of its failure. Operations reported by the
+	// httptrace hooks after Close must be ignored.
+	Close(err error)
+}
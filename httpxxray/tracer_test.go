@@ -0,0 +1,312 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogama/httpx"
+	"github.com/gogama/httpx/racing"
+	"github.com/gogama/httpx/request"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTracer is a Tracer which records spans in memory, allowing the
+// handler's lifecycle logic to be tested without the X-Ray SDK.
+type fakeTracer struct {
+	lock  sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpanKeyType int
+
+var fakeSpanKey = new(fakeSpanKeyType)
+
+func (t *fakeTracer) Begin(ctx context.Context, kind SpanKind, name string, start time.Time) (context.Context, Span) {
+	parent, _ := ctx.Value(fakeSpanKey).(*fakeSpan)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	if start.IsZero() {
+		start = time.Now()
+	}
+	s := &fakeSpan{
+		kind:        kind,
+		name:        name,
+		parent:      parent,
+		start:       start,
+		annotations: map[string]interface{}{},
+		metadata:    map[string]map[string]interface{}{},
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, fakeSpanKey, s), s
+}
+
+func (t *fakeTracer) Get(ctx context.Context) Span {
+	s, _ := ctx.Value(fakeSpanKey).(*fakeSpan)
+	if s == nil {
+		return nil
+	}
+
+	return s
+}
+
+func (t *fakeTracer) TraceHTTP(_ context.Context) ConnTrace {
+	return fakeConnTrace{}
+}
+
+func (t *fakeTracer) find(name string) *fakeSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+type fakeSpan struct {
+	lock           sync.Mutex
+	kind           SpanKind
+	name           string
+	parent         *fakeSpan
+	start          time.Time
+	method, url    string
+	status, length int
+	aws            *AWSCall
+	annotations    map[string]interface{}
+	metadata       map[string]map[string]interface{}
+	closed         bool
+	end            time.Time
+	err            error
+	cls            Classification
+}
+
+func (s *fakeSpan) SetHTTPRequest(method, url string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.method, s.url = method, url
+}
+
+func (s *fakeSpan) SetHTTPResponse(status, contentLength int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status, s.length = status, contentLength
+}
+
+func (s *fakeSpan) SetAWS(call AWSCall) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.aws = &call
+}
+
+func (s *fakeSpan) AddAnnotation(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.annotations[key] = value
+}
+
+func (s *fakeSpan) AddMetadata(namespace, key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.metadata[namespace] == nil {
+		s.metadata[namespace] = map[string]interface{}{}
+	}
+	s.metadata[namespace][key] = value
+}
+
+func (s *fakeSpan) TraceContext() TraceContext {
+	return TraceContext{TraceID: "1-5759e988-bd862e3fe1be46a994272793", ParentID: s.name}
+}
+
+func (s *fakeSpan) Close(err error, c Classification) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed, s.end, s.err, s.cls = true, time.Now(), err, c
+}
+
+type fakeConnTrace struct{}
+
+func (fakeConnTrace) ClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{}
+}
+
+func TestHandler_FakeTracer(t *testing.T) {
+	t.Run("No parent span", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.Background())
+		m := newMockLogger(t)
+		m.On("Printf", subsegmentNotStartedF, []interface{}{"BeforeExecutionStart", "foo.com"}).Once()
+		ft := &fakeTracer{}
+		h := newHandler(Config{Logger: m})
+		h.tracer = ft
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		assert.Empty(t, ft.spans)
+	})
+	t.Run("ParentResolver", func(t *testing.T) {
+		root := &fakeSpan{name: "root"}
+		e := newExecutionWithContext(t, context.Background())
+		m := newMockLogger(t)
+		ft := &fakeTracer{}
+		var released bool
+		h := newHandler(Config{Logger: m, ParentResolver: ParentResolverFunc(func(ctx context.Context, p *request.Plan) (context.Context, func()) {
+			assert.Same(t, e.Plan, p)
+			return context.WithValue(ctx, fakeSpanKey, root), func() {
+				exec := ft.find("foo.com")
+				require.NotNil(t, exec)
+				assert.True(t, exec.closed)
+				released = true
+			}
+		})})
+		h.tracer = ft
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		assert.False(t, released)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		assert.True(t, released)
+		require.Len(t, ft.spans, 1)
+		assert.Same(t, root, ft.spans[0].parent)
+	})
+	t.Run("ParentResolver[No parent]", func(t *testing.T) {
+		e := newExecutionWithContext(t, context.Background())
+		m := newMockLogger(t)
+		m.On("Printf", subsegmentNotStartedF, []interface{}{"BeforeExecutionStart", "foo.com"}).Twice()
+		ft := &fakeTracer{}
+		var released int
+		resolve := func(withParent bool) ParentResolver {
+			return ParentResolverFunc(func(ctx context.Context, _ *request.Plan) (context.Context, func()) {
+				if !withParent {
+					return nil, nil
+				}
+				return ctx, func() { released++ }
+			})
+		}
+
+		for _, withParent := range []bool{false, true} {
+			h := newHandler(Config{Logger: m, ParentResolver: resolve(withParent)})
+			h.tracer = ft
+			h.Handle(httpx.BeforeExecutionStart, e)
+			h.Handle(httpx.AfterExecutionEnd, e)
+		}
+
+		m.AssertExpectations(t)
+		assert.Equal(t, 1, released)
+		assert.Empty(t, ft.spans)
+	})
+	t.Run("AWS endpoint", func(t *testing.T) {
+		root := &fakeSpan{name: "root"}
+		p, err := request.NewPlanWithContext(context.WithValue(context.Background(), fakeSpanKey, root), "POST", "https://dynamodb.us-east-1.amazonaws.com/", nil)
+		require.NoError(t, err)
+		p.Header.Set("X-Amz-Target", "DynamoDB_20120810.Query")
+		e := &request.Execution{Plan: p}
+		ft := &fakeTracer{}
+		h := newHandler(Config{})
+		h.tracer = ft
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		e.Response = &http.Response{StatusCode: 200, Header: http.Header{"X-Amzn-Requestid": {"REQ1"}}}
+		h.Handle(httpx.AfterAttempt, e)
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		want := &AWSCall{Service: "dynamodb", Region: "us-east-1", Operation: "Query", RequestID: "REQ1"}
+		for _, name := range []string{"dynamodb.us-east-1.amazonaws.com", "Attempt:0"} {
+			s := ft.find(name)
+			require.NotNil(t, s, name)
+			assert.Equal(t, want, s.aws, name)
+		}
+	})
+	t.Run("Retry and race", func(t *testing.T) {
+		root := &fakeSpan{name: "root"}
+		e := newExecutionWithContext(t, context.WithValue(context.Background(), fakeSpanKey, root))
+		m := newMockLogger(t)
+		ft := &fakeTracer{}
+		h := newHandler(Config{Logger: m, Backoff: true, Propagator: PropagatorFunc(func(req *http.Request, tc TraceContext) {
+			req.Header.Set("X-Fake-Trace", tc.ParentID)
+		})})
+		h.tracer = ft
+
+		h.Handle(httpx.BeforeExecutionStart, e)
+		// Wave 0: one attempt, which fails with a 503.
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		assert.Equal(t, "Attempt:0", e.Request.Header.Get("X-Fake-Trace"))
+		e.Response = &http.Response{StatusCode: 503}
+		h.Handle(httpx.AfterAttempt, e)
+		// Wave 1: two racing attempts; the second wins.
+		e.Attempt, e.Wave, e.Response = 1, 1, nil
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		req1 := e.Request
+		e.Attempt = 2
+		e.Request = e.Plan.ToRequest(e.Plan.Context())
+		h.Handle(httpx.BeforeAttempt, e)
+		e.Response = &http.Response{StatusCode: 200}
+		h.Handle(httpx.BeforeReadBody, e)
+		e.Body = []byte("ok")
+		h.Handle(httpx.AfterAttempt, e)
+		e.Attempt, e.Request, e.Response, e.Body = 1, req1, nil, nil
+		e.Err = &url.Error{Op: "Get", URL: "http://foo.com", Err: racing.Redundant}
+		h.Handle(httpx.AfterAttempt, e)
+		e.Attempt, e.Err = 2, nil
+		e.Response, e.Body = &http.Response{StatusCode: 200}, []byte("ok")
+		h.Handle(httpx.AfterExecutionEnd, e)
+
+		m.AssertExpectations(t)
+		exec := ft.find("foo.com")
+		require.NotNil(t, exec)
+		assert.Same(t, root, exec.parent)
+		assert.Equal(t, ExecutionSpan, exec.kind)
+		assert.True(t, exec.closed)
+		assert.Equal(t, Classification{}, exec.cls)
+		assert.Equal(t, 3, exec.metadata["httpx"]["attempts"])
+		assert.Equal(t, 2, exec.metadata["httpx"]["waves"])
+		attempt0 := ft.find("Attempt:0")
+		require.NotNil(t, attempt0)
+		assert.Same(t, exec, attempt0.parent)
+		assert.Equal(t, AttemptSpan, attempt0.kind)
+		assert.Equal(t, Classification{Fault: true}, attempt0.cls)
+		assert.Equal(t, outcomeFailed, attempt0.metadata["httpx"]["outcome"])
+		backoff := ft.find("Backoff")
+		require.NotNil(t, backoff)
+		assert.Same(t, exec, backoff.parent)
+		assert.Equal(t, BackoffSpan, backoff.kind)
+		assert.True(t, backoff.closed)
+		attempt1 := ft.find("Attempt:1")
+		require.NotNil(t, attempt1)
+		assert.Equal(t, Classification{}, attempt1.cls)
+		assert.Nil(t, attempt1.err)
+		assert.Equal(t, outcomeCancelledByRace, attempt1.metadata["httpx"]["outcome"])
+		attempt2 := ft.find("Attempt:2")
+		require.NotNil(t, attempt2)
+		assert.Equal(t, "GET", attempt2.method)
+		assert.Equal(t, "http://foo.com", attempt2.url)
+		assert.Equal(t, 200, attempt2.status)
+		assert.Equal(t, outcomeWinner, attempt2.metadata["httpx"]["outcome"])
+		readBody := ft.find("ReadBody")
+		require.NotNil(t, readBody)
+		assert.Same(t, attempt2, readBody.parent)
+		assert.Equal(t, ReadBodySpan, readBody.kind)
+		assert.True(t, readBody.closed)
+		assert.Equal(t, 2, readBody.metadata["httpx"]["bytes_read"])
+	})
+}
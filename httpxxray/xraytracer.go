@@ -0,0 +1,368 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/v2/header"
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+)
+
+// xrayTracer is the Tracer backed by the AWS X-Ray SDK for Go. Each
+// span is an X-Ray subsegment stored in the context in the usual X-Ray
+// SDK manner, so the spans are visible to any other code using the
+// X-Ray SDK.
+type xrayTracer struct{}
+
+func (xrayTracer) Begin(ctx context.Context, kind SpanKind, name string, start time.Time) (context.Context, Span) {
+	// Without a parent, the X-Ray SDK invokes its context missing
+	// strategy, which may panic, so the SDK is not called at all. In
+	// Lambda, the SDK begins the parent itself from the trace header in
+	// the context.
+	if xray.GetSegment(ctx) == nil && ctx.Value(xray.LambdaTraceHeaderKey) == nil {
+		return ctx, nil
+	}
+
+	ctx, seg := xray.BeginSubsegment(ctx, name)
+	if seg == nil {
+		return ctx, nil
+	}
+
+	seg.Lock()
+	defer seg.Unlock()
+	// X-Ray shows the execution subsegment as the downstream node on
+	// the service map, so it goes in the "remote" namespace.
+	if kind == ExecutionSpan {
+		seg.Namespace = "remote"
+	}
+	if !start.IsZero() {
+		seg.StartTime = float64(start.UnixNano()) / float64(time.Second)
+	}
+
+	return ctx, xraySpan{seg}
+}
+
+func (xrayTracer) Get(ctx context.Context) Span {
+	seg := xray.GetSegment(ctx)
+	if seg == nil {
+		return nil
+	}
+
+	return xraySpan{seg}
+}
+
+func (xrayTracer) TraceHTTP(ctx context.Context) ConnTrace {
+	return &xrayConnTrace{opCtx: ctx}
+}
+
+type xraySpan struct {
+	seg *xray.Segment
+}
+
+func (s xraySpan) SetHTTPRequest(method, url string) {
+	s.seg.Lock()
+	defer s.seg.Unlock()
+	reqData := s.seg.GetHTTP().GetRequest()
+	reqData.Method = method
+	reqData.URL = url
+}
+
+func (s xraySpan) SetHTTPResponse(status, contentLength int) {
+	s.seg.Lock()
+	defer s.seg.Unlock()
+	respData := s.seg.GetHTTP().GetResponse()
+	respData.Status = status
+	respData.ContentLength = contentLength
+}
+
+// SetAWS fills the aws block of the subsegment. The execution
+// subsegment, which is the only one in the "remote" namespace, moves to
+// the "aws" namespace, so that X-Ray shows it as an AWS node on the
+// service map.
+func (s xraySpan) SetAWS(call AWSCall) {
+	s.seg.Lock()
+	defer s.seg.Unlock()
+	if s.seg.Namespace == "remote" {
+		s.seg.Namespace = "aws"
+	}
+	aws := s.seg.GetAWS()
+	for k, v := range map[string]string{
+		"service":    call.Service,
+		"region":     call.Region,
+		"operation":  call.Operation,
+		"request_id": call.RequestID,
+	} {
+		if v != "" {
+			aws[k] = v
+		}
+	}
+}
+
+func (s xraySpan) AddAnnotation(key string, value interface{}) {
+	_ = s.seg.AddAnnotation(key, value)
+}
+
+func (s xraySpan) AddMetadata(namespace, key string, value interface{}) {
+	_ = s.seg.AddMetadataToNamespace(namespace, key, value)
+}
+
+func (s xraySpan) TraceContext() TraceContext {
+	s.seg.Lock()
+	defer s.seg.Unlock()
+	h := s.seg.DownstreamHeader()
+	tc := TraceContext{
+		TraceID:  h.TraceID,
+		ParentID: h.ParentID,
+		Sampled:  h.SamplingDecision == header.Sampled,
+	}
+	// The downstream header shares the additional data of the segment's
+	// incoming header, so it is copied.
+	if len(h.AdditionalData) > 0 {
+		tc.AdditionalData = make(map[string]string, len(h.AdditionalData))
+		for k, v := range h.AdditionalData {
+			tc.AdditionalData[k] = v
+		}
+	}
+	return tc
+}
+
+func (s xraySpan) Close(err error, c Classification) {
+	s.seg.Lock()
+	if err != nil && !xray.SdkDisabled() {
+		addCause(s.seg, err)
+	}
+	s.seg.Fault = c.Fault
+	s.seg.Error = c.Error
+	s.seg.Throttle = c.Throttle
+	s.seg.Unlock()
+
+	s.seg.Close(nil)
+}
+
+// maxCauseDepth is the maximum number of exceptions recorded in the
+// cause of a subsegment, which guards against very deep error chains.
+const maxCauseDepth = 8
+
+// addCause records err as the cause of seg, which must be locked. The
+// X-Ray SDK records a single exception for an error, whose message is
+// usually that of a *url.Error, and whose type says nothing about what
+// actually failed. So the cause lists an exception for err and for each
+// error it wraps, such as the *net.OpError and *os.SyscallError within
+// a *url.Error, each with its own type and message. Only the outermost
+// exception has a stack trace.
+func addCause(seg *xray.Segment, err error) {
+	strategy := seg.ParentSegment.GetConfiguration().ExceptionFormattingStrategy
+	cause := seg.GetCause()
+	cause.WorkingDirectory, _ = os.Getwd()
+	for i := 0; err != nil && i < maxCauseDepth; i++ {
+		x := strategy.ExceptionFromError(err)
+		if i > 0 {
+			x.Stack = nil
+		}
+		cause.Exceptions = append(cause.Exceptions, x)
+		err = errors.Unwrap(err)
+	}
+}
+
+// xrayConnTrace records the low-level HTTP operations of a request
+// attempt as subsegments of the attempt subsegment, producing the same
+// subsegments as the X-Ray SDK's HTTPSubsegments. Unlike
+// HTTPSubsegments, it remembers each subsegment it begins, so that
+// Close can end those which are still open when the attempt ends, and
+// it ignores operations reported after the attempt ends. This keeps
+// races between the httptrace hooks and the end of the attempt from
+// leaving open subsegments behind, which would prevent X-Ray from
+// emitting the attempt and execution subsegments.
+type xrayConnTrace struct {
+	opCtx context.Context
+
+	lock    sync.Mutex
+	closed  bool
+	connCtx context.Context
+	conn    *xray.Segment
+	dns     *xray.Segment
+	dial    *xray.Segment
+	tls     *xray.Segment
+	req     *xray.Segment
+	resp    *xray.Segment
+}
+
+func (t *xrayConnTrace) ClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn:              t.getConn,
+		DNSStart:             t.dnsStart,
+		DNSDone:              t.dnsDone,
+		ConnectStart:         t.connectStart,
+		ConnectDone:          t.connectDone,
+		TLSHandshakeStart:    t.tlsHandshakeStart,
+		TLSHandshakeDone:     t.tlsHandshakeDone,
+		GotConn:              t.gotConn,
+		WroteRequest:         t.wroteRequest,
+		GotFirstResponseByte: t.gotFirstResponseByte,
+	}
+}
+
+// Close ends the subsegments which are still open, recording err as
+// the cause of their failure, for example when the attempt failed while
+// connecting. Operations reported after Close are ignored.
+func (t *xrayConnTrace) Close(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return
+	}
+
+	t.closed = true
+	for _, seg := range []*xray.Segment{t.dns, t.dial, t.tls, t.conn, t.req, t.resp} {
+		if inProgress(seg) {
+			seg.Close(err)
+		}
+	}
+}
+
+// active reports whether operations may still be recorded. The caller
+// must hold the lock.
+func (t *xrayConnTrace) active() bool {
+	return !t.closed && inProgress(xray.GetSegment(t.opCtx))
+}
+
+func (t *xrayConnTrace) getConn(_ string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() {
+		t.connCtx, t.conn = xray.BeginSubsegment(t.opCtx, "connect")
+	}
+}
+
+func (t *xrayConnTrace) dnsStart(_ httptrace.DNSStartInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && t.conn != nil {
+		_, t.dns = xray.BeginSubsegment(t.connCtx, "dns")
+	}
+}
+
+func (t *xrayConnTrace) dnsDone(info httptrace.DNSDoneInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && inProgress(t.dns) {
+		_ = t.dns.AddMetadataToNamespace("http", "dns", map[string]interface{}{
+			"addresses": info.Addrs,
+			"coalesced": info.Coalesced,
+		})
+		t.dns.Close(info.Err)
+	}
+}
+
+func (t *xrayConnTrace) connectStart(_, _ string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && t.conn != nil {
+		_, t.dial = xray.BeginSubsegment(t.connCtx, "dial")
+	}
+}
+
+func (t *xrayConnTrace) connectDone(network, _ string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && inProgress(t.dial) {
+		_ = t.dial.AddMetadataToNamespace("http", "connect", map[string]interface{}{
+			"network": network,
+		})
+		t.dial.Close(err)
+	}
+}
+
+func (t *xrayConnTrace) tlsHandshakeStart() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && t.conn != nil {
+		_, t.tls = xray.BeginSubsegment(t.connCtx, "tls")
+	}
+}
+
+func (t *xrayConnTrace) tlsHandshakeDone(connState tls.ConnectionState, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && inProgress(t.tls) {
+		_ = t.tls.AddMetadataToNamespace("http", "tls", map[string]interface{}{
+			"did_resume":                    connState.DidResume,
+			"negotiated_protocol":           connState.NegotiatedProtocol,
+			"negotiated_protocol_is_mutual": connState.NegotiatedProtocolIsMutual,
+			"cipher_suite":                  connState.CipherSuite,
+		})
+		t.tls.Close(err)
+	}
+}
+
+func (t *xrayConnTrace) gotConn(info httptrace.GotConnInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.active() || t.conn == nil {
+		return
+	}
+
+	// A reused connection needed no connecting, so the connect
+	// subsegment is discarded, as the X-Ray SDK does.
+	if info.Reused {
+		xray.GetSegment(t.opCtx).RemoveSubsegment(t.conn)
+		t.connCtx, t.conn = nil, nil
+	} else if inProgress(t.conn) {
+		metadata := map[string]interface{}{
+			"reused":   info.Reused,
+			"was_idle": info.WasIdle,
+		}
+		if info.WasIdle {
+			metadata["idle_time"] = info.IdleTime
+		}
+		_ = t.conn.AddMetadataToNamespace("http", "connection", metadata)
+		t.conn.Close(nil)
+	}
+	_, t.req = xray.BeginSubsegment(t.opCtx, "request")
+}
+
+func (t *xrayConnTrace) wroteRequest(info httptrace.WroteRequestInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.active() {
+		return
+	}
+
+	if inProgress(t.req) {
+		t.req.Close(info.Err)
+		_, t.resp = xray.BeginSubsegment(t.opCtx, "response")
+	}
+	// A connection must have been acquired to write the request, even
+	// if GotConn was not reported.
+	if inProgress(t.conn) {
+		t.conn.Close(nil)
+	}
+}
+
+func (t *xrayConnTrace) gotFirstResponseByte() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.active() && inProgress(t.resp) {
+		t.resp.Close(nil)
+	}
+}
+
+// inProgress reports whether seg is an open subsegment.
+func inProgress(seg *xray.Segment) bool {
+	if seg == nil {
+		return false
+	}
+
+	seg.RLock()
+	defer seg.RUnlock()
+	return seg.InProgress
+}
@@ -0,0 +1,338 @@
+// Copyright 2026 The httpxxray Authors. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package httpxxray
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/v2/header"
+	"github.com/aws/aws-xray-sdk-go/v2/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/v2/xray"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXRayTracer(t *testing.T) {
+	t.Run("Begin[No parent segment]", func(t *testing.T) {
+		_, s := xrayTracer{}.Begin(context.Background(), AttemptSpan, "foo", time.Time{})
+
+		assert.Nil(t, s)
+	})
+	t.Run("Begin[No parent segment, runtime error strategy]", func(t *testing.T) {
+		ctx, err := xray.ContextWithConfig(context.Background(), xray.Config{
+			ContextMissingStrategy: ctxmissing.NewDefaultRuntimeErrorStrategy(),
+		})
+		require.NoError(t, err)
+
+		_, s := xrayTracer{}.Begin(ctx, ExecutionSpan, "foo", time.Time{})
+
+		assert.Nil(t, s)
+	})
+	t.Run("Begin[With parent segment]", func(t *testing.T) {
+		ctx, s := xrayTracer{}.Begin(parentCtx, AttemptSpan, "foo", time.Time{})
+
+		require.NotNil(t, s)
+		seg := s.(xraySpan).seg
+		defer seg.Close(nil)
+		assert.Equal(t, "foo", seg.Name)
+		assert.Equal(t, "", seg.Namespace)
+		assert.Same(t, seg, xray.GetSegment(ctx))
+	})
+	t.Run("Begin[ExecutionSpan]", func(t *testing.T) {
+		_, s := xrayTracer{}.Begin(parentCtx, ExecutionSpan, "foo", time.Time{})
+
+		require.NotNil(t, s)
+		seg := s.(xraySpan).seg
+		defer seg.Close(nil)
+		assert.Equal(t, "remote", seg.Namespace)
+	})
+	t.Run("Begin[Start time]", func(t *testing.T) {
+		start := time.Unix(1600000000, 500000000)
+
+		_, s := xrayTracer{}.Begin(parentCtx, BackoffSpan, "foo", start)
+
+		require.NotNil(t, s)
+		seg := s.(xraySpan).seg
+		defer seg.Close(nil)
+		assert.Equal(t, 1600000000.5, seg.StartTime)
+	})
+	t.Run("Get[No segment]", func(t *testing.T) {
+		s := xrayTracer{}.Get(context.Background())
+
+		assert.Nil(t, s)
+	})
+	t.Run("Get[With segment]", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		s := xrayTracer{}.Get(ctx)
+
+		require.NotNil(t, s)
+		assert.Same(t, seg, s.(xraySpan).seg)
+	})
+	t.Run("TraceHTTP", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		trace := xrayTracer{}.TraceHTTP(ctx)
+
+		require.IsType(t, &xrayConnTrace{}, trace)
+		assert.Implements(t, (*ConnTraceCloser)(nil), trace)
+		assert.Equal(t, ctx, trace.(*xrayConnTrace).opCtx)
+		assert.NotNil(t, trace.ClientTrace())
+	})
+}
+
+func TestXRaySpan(t *testing.T) {
+	t.Run("SetHTTPRequest", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		xraySpan{seg}.SetHTTPRequest("POST", "https://foo.com/bar")
+
+		assert.Equal(t, "POST", seg.GetHTTP().GetRequest().Method)
+		assert.Equal(t, "https://foo.com/bar", seg.GetHTTP().GetRequest().URL)
+	})
+	t.Run("SetHTTPResponse", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		xraySpan{seg}.SetHTTPResponse(404, 11)
+
+		assert.Equal(t, 404, seg.GetHTTP().GetResponse().Status)
+		assert.Equal(t, 11, seg.GetHTTP().GetResponse().ContentLength)
+		assert.False(t, seg.Error)
+	})
+	t.Run("AddAnnotation", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		xraySpan{seg}.AddAnnotation("foo", 1)
+
+		assert.Equal(t, map[string]interface{}{"foo": 1}, seg.Annotations)
+	})
+	t.Run("AddMetadata", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		xraySpan{seg}.AddMetadata("ns", "foo", "bar")
+
+		assert.Equal(t, map[string]interface{}{"foo": "bar"}, seg.Metadata["ns"])
+	})
+	t.Run("TraceContext", func(t *testing.T) {
+		_, seg := newSampledSegment(t)
+		defer seg.Close(nil)
+
+		tc := xraySpan{seg}.TraceContext()
+
+		assert.Equal(t, TraceContext{TraceID: seg.TraceID, ParentID: seg.ID, Sampled: true}, tc)
+		req := &http.Request{Header: http.Header{}}
+		XRayPropagator.Inject(req, tc)
+		assert.Equal(t, seg.DownstreamHeader().String(), req.Header.Get(xray.TraceIDHeaderKey))
+	})
+	t.Run("TraceContext[Incoming header data]", func(t *testing.T) {
+		ctx, seg := newSampledSegment(t)
+		defer seg.Close(nil)
+		seg.Lock()
+		seg.IncomingHeader = header.FromString("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;Lineage=a87bd80c:1|68fd508a:5")
+		seg.Unlock()
+		_, s := xrayTracer{}.Begin(ctx, AttemptSpan, "foo", time.Time{})
+		require.NotNil(t, s)
+		sub := s.(xraySpan).seg
+		defer sub.Close(nil)
+
+		tc := s.TraceContext()
+		tc.AdditionalData["Lineage"] = "changed"
+		tc = s.TraceContext()
+
+		assert.Equal(t, TraceContext{
+			TraceID:        "1-5759e988-bd862e3fe1be46a994272793",
+			ParentID:       sub.ID,
+			Sampled:        true,
+			AdditionalData: map[string]string{"Lineage": "a87bd80c:1|68fd508a:5"},
+		}, tc)
+		req := &http.Request{Header: http.Header{}}
+		XRayPropagator.Inject(req, tc)
+		assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Parent="+sub.ID+";Sampled=1;Lineage=a87bd80c:1|68fd508a:5", req.Header.Get(xray.TraceIDHeaderKey))
+	})
+	t.Run("SetAWS", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		_, s := xrayTracer{}.Begin(ctx, ExecutionSpan, "dynamodb.us-east-1.amazonaws.com", time.Time{})
+		require.NotNil(t, s)
+		execSeg := s.(xraySpan).seg
+
+		require.Implements(t, (*AWSSpan)(nil), s)
+		s.(AWSSpan).SetAWS(AWSCall{Service: "dynamodb", Region: "us-east-1", Operation: "GetItem"})
+
+		assert.Equal(t, "aws", execSeg.Namespace)
+		assert.Equal(t, map[string]interface{}{
+			"service":   "dynamodb",
+			"region":    "us-east-1",
+			"operation": "GetItem",
+		}, execSeg.AWS)
+	})
+	t.Run("SetAWS[Attempt]", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+
+		xraySpan{seg}.SetAWS(AWSCall{RequestID: "abc"})
+
+		assert.Empty(t, seg.Namespace)
+		assert.Equal(t, map[string]interface{}{"request_id": "abc"}, seg.AWS)
+	})
+	t.Run("Close[No error]", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+
+		xraySpan{seg}.Close(nil, Classification{Error: true, Throttle: true})
+
+		assert.False(t, seg.InProgress)
+		assert.False(t, seg.Fault)
+		assert.True(t, seg.Error)
+		assert.True(t, seg.Throttle)
+		assert.Nil(t, seg.Cause)
+	})
+	t.Run("Close[Error not classified as fault]", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+
+		xraySpan{seg}.Close(errors.New("qux"), Classification{})
+
+		assert.False(t, seg.InProgress)
+		assert.False(t, seg.Fault)
+		assert.False(t, seg.Error)
+		assert.False(t, seg.Throttle)
+		require.NotNil(t, seg.Cause)
+		require.Len(t, seg.Cause.Exceptions, 1)
+		assert.Equal(t, "qux", seg.Cause.Exceptions[0].Message)
+	})
+	t.Run("Close[Wrapped error]", func(t *testing.T) {
+		_, seg := newNonDummySegment(t)
+		errno := os.NewSyscallError("connect", syscall.ECONNREFUSED)
+		err := &url.Error{Op: "Get", URL: "http://foo.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errno}}
+
+		xraySpan{seg}.Close(err, Classification{Fault: true})
+
+		assert.True(t, seg.Fault)
+		require.NotNil(t, seg.Cause)
+		assert.NotEmpty(t, seg.Cause.WorkingDirectory)
+		x := seg.Cause.Exceptions
+		require.Len(t, x, 4)
+		assert.Equal(t, "url.Error", x[0].Type)
+		assert.Equal(t, err.Error(), x[0].Message)
+		assert.NotEmpty(t, x[0].Stack)
+		assert.Equal(t, "net.OpError", x[1].Type)
+		assert.Equal(t, "os.SyscallError", x[2].Type)
+		assert.Equal(t, "syscall.Errno", x[3].Type)
+		assert.Equal(t, "connection refused", x[3].Message)
+		for i := 1; i < len(x); i++ {
+			assert.Empty(t, x[i].Stack)
+			assert.NotEqual(t, x[i-1].ID, x[i].ID)
+		}
+	})
+}
+
+func TestXRayConnTrace(t *testing.T) {
+	t.Run("Complete", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		trace := xrayTracer{}.TraceHTTP(ctx).(*xrayConnTrace)
+		ct := trace.ClientTrace()
+
+		ct.GetConn("foo.com:443")
+		ct.DNSStart(httptrace.DNSStartInfo{Host: "foo.com"})
+		ct.DNSDone(httptrace.DNSDoneInfo{})
+		ct.ConnectStart("tcp", "127.0.0.1:443")
+		ct.ConnectDone("tcp", "127.0.0.1:443", nil)
+		ct.TLSHandshakeStart()
+		ct.TLSHandshakeDone(tls.ConnectionState{NegotiatedProtocol: "h2"}, nil)
+		ct.GotConn(httptrace.GotConnInfo{})
+		ct.WroteRequest(httptrace.WroteRequestInfo{})
+		ct.GotFirstResponseByte()
+		trace.Close(errors.New("too late"))
+
+		subsegments := []*xray.Segment{trace.conn, trace.dns, trace.dial, trace.tls, trace.req, trace.resp}
+		names := []string{"connect", "dns", "dial", "tls", "request", "response"}
+		for i, sub := range subsegments {
+			require.NotNil(t, sub, names[i])
+			assert.Equal(t, names[i], sub.Name)
+			assert.False(t, sub.InProgress, names[i])
+			assert.False(t, sub.Fault, names[i])
+		}
+	})
+	t.Run("Close[Failed while connecting]", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		trace := xrayTracer{}.TraceHTTP(ctx).(*xrayConnTrace)
+		ct := trace.ClientTrace()
+
+		ct.GetConn("foo.com:443")
+		ct.DNSStart(httptrace.DNSStartInfo{Host: "foo.com"})
+		ct.DNSDone(httptrace.DNSDoneInfo{})
+		ct.ConnectStart("tcp", "127.0.0.1:443")
+		trace.Close(errors.New("dial timeout"))
+		ct.ConnectDone("tcp", "127.0.0.1:443", errors.New("ignored"))
+		ct.TLSHandshakeStart()
+
+		assert.False(t, trace.dns.InProgress)
+		assert.False(t, trace.dns.Fault)
+		for _, sub := range []*xray.Segment{trace.dial, trace.conn} {
+			assert.False(t, sub.InProgress)
+			assert.True(t, sub.Fault)
+			require.NotNil(t, sub.Cause)
+			require.Len(t, sub.Cause.Exceptions, 1)
+			assert.Equal(t, "dial timeout", sub.Cause.Exceptions[0].Message)
+		}
+		assert.Nil(t, trace.tls)
+	})
+	t.Run("Close[No error]", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		trace := xrayTracer{}.TraceHTTP(ctx).(*xrayConnTrace)
+		ct := trace.ClientTrace()
+
+		ct.GetConn("foo.com:443")
+		trace.Close(nil)
+		trace.Close(errors.New("closed twice"))
+
+		assert.False(t, trace.conn.InProgress)
+		assert.False(t, trace.conn.Fault)
+	})
+	t.Run("Reused connection", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		defer seg.Close(nil)
+		trace := xrayTracer{}.TraceHTTP(ctx).(*xrayConnTrace)
+		ct := trace.ClientTrace()
+
+		ct.GetConn("foo.com:443")
+		ct.GotConn(httptrace.GotConnInfo{Reused: true})
+
+		assert.Nil(t, trace.conn)
+		require.NotNil(t, trace.req)
+		assert.True(t, trace.req.InProgress)
+
+		trace.Close(nil)
+
+		assert.False(t, trace.req.InProgress)
+	})
+	t.Run("Attempt closed", func(t *testing.T) {
+		ctx, seg := newNonDummySegment(t)
+		trace := xrayTracer{}.TraceHTTP(ctx).(*xrayConnTrace)
+		seg.Close(nil)
+
+		trace.ClientTrace().GetConn("foo.com:443")
+
+		assert.Nil(t, trace.conn)
+	})
+}